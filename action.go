@@ -0,0 +1,159 @@
+package main
+
+import (
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3iface"
+)
+
+// Action is what deleteObjects does to each batch. The built in default,
+// deleteAction, deletes. The others tag, archive first or only pretend.
+// Whatever they do, every version in the batch gets a Result, so the
+// batching, concurrency, progress, reporting and failure handling around
+// them is the same for every action.
+//
+// An error is only returned when the whole batch failed.
+type Action interface {
+	Apply(ctx aws.Context, client s3iface.S3API, batch *Batch) ([]Result, error)
+}
+
+// Batch is the versions an Action is applied to in one go.
+type Batch struct {
+	Bucket  string
+	Objects []*s3.ObjectIdentifier
+	// Options are added to the request sent for the whole batch. Actions
+	// that send a request per version ignore them.
+	Options []request.Option
+}
+
+// Result is what happened to one version in a batch.
+type Result struct {
+	Key       string
+	VersionId string
+	// DeleteMarker is set when the version was a delete marker, or when
+	// deleting without a version id added one.
+	DeleteMarker bool
+	// Err is set when the action failed for this version.
+	Err *s3.Error
+}
+
+// resultFor returns the Result of a version the action dealt with.
+func resultFor(id *s3.ObjectIdentifier) Result {
+	return Result{Key: aws.StringValue(id.Key), VersionId: aws.StringValue(id.VersionId)}
+}
+
+// failedResult returns the Result of a version the action failed on.
+func failedResult(id *s3.ObjectIdentifier, err *s3.Error) Result {
+	r := resultFor(id)
+	r.Err = err
+	return r
+}
+
+// resultErrors returns the failures in results.
+func resultErrors(results []Result) []*s3.Error {
+	errs := []*s3.Error{}
+	for _, r := range results {
+		if r.Err != nil {
+			errs = append(errs, r.Err)
+		}
+	}
+	return errs
+}
+
+// deleteAction sends each batch to DeleteObjects. With quiet S3 only sends
+// back the errors, which makes the responses to large batches much smaller,
+// and the versions it leaves out are filled back in from the batch so the
+// results are the same.
+type deleteAction struct {
+	quiet bool
+	// deleteMarkers holds the key and version id of every delete marker that
+	// may be in a batch, a quiet response no longer says which were markers.
+	deleteMarkers map[string]bool
+}
+
+func newDeleteAction(quiet bool, deleteMarkers []*s3.DeleteMarkerEntry) *deleteAction {
+	return &deleteAction{quiet: quiet, deleteMarkers: deleteMarkerSet(deleteMarkers)}
+}
+
+func (a *deleteAction) Apply(ctx aws.Context, client s3iface.S3API, batch *Batch) ([]Result, error) {
+	out, err := client.DeleteObjectsWithContext(ctx, &s3.DeleteObjectsInput{
+		Bucket: aws.String(batch.Bucket),
+		Delete: &s3.Delete{Objects: batch.Objects, Quiet: aws.Bool(a.quiet)},
+	}, batch.Options...)
+	if err != nil {
+		return nil, err
+	}
+	if a.quiet {
+		return quietResults(batch, out.Errors, a.deleteMarkers), nil
+	}
+
+	results := make([]Result, 0, len(out.Deleted)+len(out.Errors))
+	for _, d := range out.Deleted {
+		results = append(results, Result{
+			Key:          aws.StringValue(d.Key),
+			VersionId:    aws.StringValue(d.VersionId),
+			DeleteMarker: aws.BoolValue(d.DeleteMarker),
+		})
+	}
+	for _, e := range out.Errors {
+		results = append(results, Result{Key: aws.StringValue(e.Key), VersionId: aws.StringValue(e.VersionId), Err: e})
+	}
+	return results, nil
+}
+
+// newAction picks the action for the delete options. canFallBack is set if
+// a batch that fails with NotImplemented can be sent again through
+// fallbackAction, which only makes sense against a real endpoint.
+func newAction(opts deleteOptions, deleteMarkers []*s3.DeleteMarkerEntry) (action Action, canFallBack bool) {
+	action, canFallBack = newUnlimitedAction(opts, deleteMarkers)
+	return limitRequests(opts, action), canFallBack
+}
+
+// newUnlimitedAction picks the action without the request limit.
+func newUnlimitedAction(opts deleteOptions, deleteMarkers []*s3.DeleteMarkerEntry) (Action, bool) {
+	switch {
+	case opts.simulate:
+		return newSimulatedAction(opts.simulateLatency), false
+	case opts.tag != nil:
+		return newTaggingAction(opts.tag), false
+	case opts.singleDelete:
+		return archiveFirst(opts, singleDeleteAction{}, deleteMarkers), false
+	}
+	return archiveFirst(opts, newDeleteAction(opts.quietDelete, deleteMarkers), deleteMarkers), true
+}
+
+// fallbackAction is used once the endpoint turns out not to support
+// DeleteObjects.
+func fallbackAction(opts deleteOptions, deleteMarkers []*s3.DeleteMarkerEntry) Action {
+	return limitRequests(opts, archiveFirst(opts, singleDeleteAction{}, deleteMarkers))
+}
+
+// archiveFirst wraps action so each batch is copied to the archive bucket
+// first, when -archive-bucket is used.
+func archiveFirst(opts deleteOptions, action Action, deleteMarkers []*s3.DeleteMarkerEntry) Action {
+	if opts.archiveBucket == "" {
+		return action
+	}
+	return newArchivingAction(opts.archiveBucket, action, deleteMarkers)
+}
+
+// limitedAction waits for a free slot before passing each batch on, so the
+// batches in flight never outnumber the slots.
+type limitedAction struct {
+	next  Action
+	slots chan struct{}
+}
+
+func limitRequests(opts deleteOptions, action Action) Action {
+	if opts.requestSlots == nil {
+		return action
+	}
+	return &limitedAction{next: action, slots: opts.requestSlots}
+}
+
+func (a *limitedAction) Apply(ctx aws.Context, client s3iface.S3API, batch *Batch) ([]Result, error) {
+	a.slots <- struct{}{}
+	defer func() { <-a.slots }()
+	return a.next.Apply(ctx, client, batch)
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"errors"
+	"sort"
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3iface"
+)
+
+// fakeS3 answers the requests the actions send. Copies of keys in failCopy
+// fail, everything else works.
+type fakeS3 struct {
+	s3iface.S3API
+
+	lock     sync.Mutex
+	copied   []string
+	deleted  [][]string
+	failCopy map[string]bool
+}
+
+func (f *fakeS3) CopyObjectWithContext(_ aws.Context, input *s3.CopyObjectInput, _ ...request.Option) (*s3.CopyObjectOutput, error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	if f.failCopy[aws.StringValue(input.Key)] {
+		return nil, errors.New("copy refused")
+	}
+	f.copied = append(f.copied, aws.StringValue(input.Key))
+	return &s3.CopyObjectOutput{}, nil
+}
+
+func (f *fakeS3) DeleteObjectsWithContext(_ aws.Context, input *s3.DeleteObjectsInput, _ ...request.Option) (*s3.DeleteObjectsOutput, error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	keys := []string{}
+	out := &s3.DeleteObjectsOutput{}
+	for _, id := range input.Delete.Objects {
+		keys = append(keys, aws.StringValue(id.Key))
+		if !aws.BoolValue(input.Delete.Quiet) {
+			out.Deleted = append(out.Deleted, &s3.DeletedObject{Key: id.Key, VersionId: id.VersionId})
+		}
+	}
+	f.deleted = append(f.deleted, keys)
+	return out, nil
+}
+
+func resultKeys(results []Result) (done, failed []string) {
+	done, failed = []string{}, []string{}
+	for _, r := range results {
+		if r.Err != nil {
+			failed = append(failed, r.Key)
+		} else {
+			done = append(done, r.Key)
+		}
+	}
+	sort.Strings(done)
+	sort.Strings(failed)
+	return done, failed
+}
+
+// The delete markers are put aside while the copy workers run, which -race
+// checks.
+func TestArchivingActionWithDeleteMarkers(t *testing.T) {
+	objects := []*s3.ObjectIdentifier{}
+	markers := []*s3.DeleteMarkerEntry{}
+	for i := 0; i < 200; i++ {
+		id := &s3.ObjectIdentifier{Key: aws.String("key" + strconv.Itoa(i)), VersionId: aws.String("v")}
+		objects = append(objects, id)
+		if i%2 == 0 {
+			markers = append(markers, &s3.DeleteMarkerEntry{Key: id.Key, VersionId: id.VersionId})
+		}
+	}
+	client := &fakeS3{}
+	action := newArchivingAction("archive", newDeleteAction(false, markers), markers)
+	results, err := action.Apply(aws.BackgroundContext(), client, &Batch{Bucket: "bucket", Objects: objects})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != len(objects) {
+		t.Errorf("got %d results, want %d", len(results), len(objects))
+	}
+	if len(client.copied) != len(objects)-len(markers) {
+		t.Errorf("copied %d versions, want %d, delete markers are not copied", len(client.copied), len(objects)-len(markers))
+	}
+}
+
+func TestArchivingActionKeepsWhatFailedToCopy(t *testing.T) {
+	markers := []*s3.DeleteMarkerEntry{{Key: aws.String("marker"), VersionId: aws.String("m1")}}
+	client := &fakeS3{failCopy: map[string]bool{"b": true}}
+	action := newArchivingAction("archive", newDeleteAction(true, markers), markers)
+	batch := &Batch{Bucket: "bucket", Objects: identifiers("a", "1", "b", "1", "c", "1", "marker", "m1")}
+	results, err := action.Apply(aws.BackgroundContext(), client, batch)
+	if err != nil {
+		t.Fatal(err)
+	}
+	done, failed := resultKeys(results)
+	if !sameStrings(done, []string{"a", "c", "marker"}) || !sameStrings(failed, []string{"b"}) {
+		t.Errorf("done %v and failed %v, want a, c and marker done and b failed", done, failed)
+	}
+	if len(client.deleted) != 1 || len(client.deleted[0]) != 3 {
+		t.Errorf("deleted %v, want one batch without b", client.deleted)
+	}
+	for _, r := range results {
+		if r.Key == "marker" && !r.DeleteMarker {
+			t.Error("the delete marker was not reported as one")
+		}
+	}
+}
+
+func TestDeleteActionQuietFillsInResults(t *testing.T) {
+	markers := []*s3.DeleteMarkerEntry{{Key: aws.String("b"), VersionId: aws.String("2")}}
+	batch := &Batch{Bucket: "bucket", Objects: identifiers("a", "1", "b", "2")}
+	for _, quiet := range []bool{false, true} {
+		results, err := newDeleteAction(quiet, markers).Apply(aws.BackgroundContext(), &fakeS3{}, batch)
+		if err != nil {
+			t.Fatal(err)
+		}
+		done, failed := resultKeys(results)
+		if !sameStrings(done, []string{"a", "b"}) || len(failed) != 0 {
+			t.Errorf("quiet %v: done %v and failed %v, want a and b done", quiet, done, failed)
+		}
+	}
+}
+
+func TestLimitedActionHoldsASlot(t *testing.T) {
+	slots := make(chan struct{}, 1)
+	action := limitRequests(deleteOptions{requestSlots: slots}, newSimulatedAction(0))
+	if _, err := action.Apply(aws.BackgroundContext(), nil, &Batch{Objects: identifiers("a", "1")}); err != nil {
+		t.Fatal(err)
+	}
+	if len(slots) != 0 {
+		t.Error("the slot was not given back")
+	}
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// parseCutoff turns an age or a date into a point in time. An age is a Go
+// duration like 36h, or a number of days like 90d, and is counted back from
+// now. A date is either 2006-01-02, taken as midnight UTC, or RFC3339.
+func parseCutoff(value string, now time.Time) (time.Time, error) {
+	if days := strings.TrimSuffix(value, "d"); days != value {
+		n, err := strconv.Atoi(days)
+		if err == nil && n >= 0 {
+			return now.AddDate(0, 0, -n), nil
+		}
+	}
+	if d, err := time.ParseDuration(value); err == nil && d >= 0 {
+		return now.Add(-d), nil
+	}
+	if t, err := time.Parse("2006-01-02", value); err == nil {
+		return t, nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	return time.Time{}, fmt.Errorf("%q is not an age like 90d or 12h, or a date like 2023-01-01", value)
+}
+
+// newAgeFilter matches versions last modified before cutoff, or with newer,
+// after it. Versions with no LastModified, which only come from old
+// manifests, never match.
+func newAgeFilter(cutoff time.Time, newer bool) versionFilter {
+	return func(obj object) bool {
+		if obj.LastModified == nil {
+			return false
+		}
+		if newer {
+			return obj.LastModified.After(cutoff)
+		}
+		return obj.LastModified.Before(cutoff)
+	}
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseCutoff(t *testing.T) {
+	now := time.Date(2023, 6, 15, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		value string
+		want  time.Time
+	}{
+		{"90d", time.Date(2023, 3, 17, 12, 0, 0, 0, time.UTC)},
+		{"0d", now},
+		{"36h", time.Date(2023, 6, 14, 0, 0, 0, 0, time.UTC)},
+		{"90m", time.Date(2023, 6, 15, 10, 30, 0, 0, time.UTC)},
+		{"2023-01-01", time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)},
+		{"2023-01-01T10:30:00Z", time.Date(2023, 1, 1, 10, 30, 0, 0, time.UTC)},
+	}
+	for _, tt := range tests {
+		got, err := parseCutoff(tt.value, now)
+		if err != nil {
+			t.Errorf("parseCutoff(%q) failed: %s", tt.value, err)
+			continue
+		}
+		if !got.Equal(tt.want) {
+			t.Errorf("parseCutoff(%q) = %s, want %s", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestParseCutoffInvalid(t *testing.T) {
+	now := time.Date(2023, 6, 15, 12, 0, 0, 0, time.UTC)
+	for _, value := range []string{"", "d", "-5d", "-1h", "ten", "90 days", "2023-13-01", "01/02/2023"} {
+		if got, err := parseCutoff(value, now); err == nil {
+			t.Errorf("parseCutoff(%q) = %s, want an error", value, got)
+		}
+	}
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+)
+
+// bucketAllowPattern can be baked into a build with
+// -ldflags "-X main.bucketAllowPattern=^test-.*", so that binary can only
+// ever empty matching buckets. It applies on top of -bucket-allow-pattern
+// and can not be turned off at run time.
+var bucketAllowPattern = ""
+
+// compileAllowPatterns compiles the non empty patterns. Each must match the
+// whole bucket name, not just part of it.
+func compileAllowPatterns(patterns ...string) ([]*regexp.Regexp, error) {
+	compiled := []*regexp.Regexp{}
+	for _, pattern := range patterns {
+		if pattern == "" {
+			continue
+		}
+		re, err := regexp.Compile("^(?:" + pattern + ")$")
+		if err != nil {
+			return nil, fmt.Errorf("%q is not a valid regular expression: %s", pattern, err)
+		}
+		compiled = append(compiled, re)
+	}
+	return compiled, nil
+}
+
+// disallowedBuckets returns the buckets that do not match every pattern.
+func disallowedBuckets(buckets []string, patterns []*regexp.Regexp) []string {
+	refused := []string{}
+	for _, bucket := range buckets {
+		for _, re := range patterns {
+			if !re.MatchString(bucket) {
+				refused = append(refused, bucket)
+				break
+			}
+		}
+	}
+	return refused
+}
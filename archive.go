@@ -0,0 +1,111 @@
+package main
+
+import (
+	"net/url"
+	"strings"
+	"sync"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3iface"
+)
+
+// archiveWorkers is how many objects in a batch are copied at the same time.
+const archiveWorkers = 10
+
+// archivingAction copies each object version in a batch to another bucket,
+// under the same key, and then hands the versions that copied to next. A
+// version that fails to copy is reported as an error and left in place, so
+// nothing is deleted without an archived copy.
+//
+// Delete markers have no data so are not copied, they are passed straight to
+// next. CopyObject is limited to 5 GiB, larger objects fail to copy and are
+// kept.
+type archivingAction struct {
+	archiveBucket string
+	next          Action
+	// deleteMarkers holds the key and version id of every delete marker that
+	// may be in a batch.
+	deleteMarkers map[string]bool
+}
+
+func newArchivingAction(archiveBucket string, next Action, deleteMarkers []*s3.DeleteMarkerEntry) *archivingAction {
+	return &archivingAction{archiveBucket: archiveBucket, next: next, deleteMarkers: deleteMarkerSet(deleteMarkers)}
+}
+
+// deleteMarkerSet indexes the delete markers by versionKey.
+func deleteMarkerSet(deleteMarkers []*s3.DeleteMarkerEntry) map[string]bool {
+	markers := make(map[string]bool, len(deleteMarkers))
+	for _, dm := range deleteMarkers {
+		markers[versionKey(dm.Key, dm.VersionId)] = true
+	}
+	return markers
+}
+
+func versionKey(key, versionId *string) string {
+	return aws.StringValue(key) + "\x00" + aws.StringValue(versionId)
+}
+
+func (a *archivingAction) Apply(ctx aws.Context, client s3iface.S3API, batch *Batch) ([]Result, error) {
+	// markers is only used here, copied is shared with the workers.
+	markers := []*s3.ObjectIdentifier{}
+	copied := []*s3.ObjectIdentifier{}
+	copyFailures := []Result{}
+	lock := sync.Mutex{}
+	work := make(chan *s3.ObjectIdentifier)
+	wg := sync.WaitGroup{}
+	for i := 0; i < archiveWorkers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for id := range work {
+				err := a.copyObject(ctx, client, batch.Bucket, id)
+				lock.Lock()
+				if err != nil {
+					copyFailures = append(copyFailures, failedResult(id, objectError(id, "archive copy failed: ", err)))
+				} else {
+					copied = append(copied, id)
+				}
+				lock.Unlock()
+			}
+		}()
+	}
+	for _, id := range batch.Objects {
+		if a.deleteMarkers[versionKey(id.Key, id.VersionId)] {
+			markers = append(markers, id)
+			continue
+		}
+		work <- id
+	}
+	close(work)
+	wg.Wait()
+
+	results := []Result{}
+	if toDelete := append(markers, copied...); len(toDelete) > 0 {
+		next := *batch
+		next.Objects = toDelete
+		var err error
+		results, err = a.next.Apply(ctx, client, &next)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return append(results, copyFailures...), nil
+}
+
+func (a *archivingAction) copyObject(ctx aws.Context, client s3iface.S3API, bucket string, id *s3.ObjectIdentifier) error {
+	segments := strings.Split(aws.StringValue(id.Key), "/")
+	for i, segment := range segments {
+		segments[i] = url.PathEscape(segment)
+	}
+	source := bucket + "/" + strings.Join(segments, "/")
+	if versionId := aws.StringValue(id.VersionId); versionId != "" {
+		source += "?versionId=" + url.QueryEscape(versionId)
+	}
+	_, err := client.CopyObjectWithContext(ctx, &s3.CopyObjectInput{
+		Bucket:     aws.String(a.archiveBucket),
+		Key:        id.Key,
+		CopySource: aws.String(source),
+	})
+	return err
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"fmt"
+	"math/rand"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/client"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+const (
+	minBackoffDelay = 100 * time.Millisecond
+	maxBackoffDelay = 20 * time.Second
+)
+
+// adaptiveBackoff holds every request back by a delay that doubles each time
+// S3 asks for less traffic, with SlowDown or a 503, and shrinks again as
+// requests succeed. The SDK only backs off the one request that was
+// throttled, this slows down everything else sharing the session too.
+type adaptiveBackoff struct {
+	lock  sync.Mutex
+	delay time.Duration
+}
+
+// addAdaptiveBackoff adds the backoff to every request made with the
+// session.
+func addAdaptiveBackoff(awsSession *session.Session) {
+	b := &adaptiveBackoff{}
+	awsSession.Handlers.Send.PushFront(b.wait)
+	awsSession.Handlers.CompleteAttempt.PushBack(b.observe)
+}
+
+// wait sleeps for somewhere between half and all of the current delay, the
+// jitter keeps requests that were held back together from going out
+// together.
+func (b *adaptiveBackoff) wait(r *request.Request) {
+	b.lock.Lock()
+	delay := b.delay
+	b.lock.Unlock()
+	if delay == 0 {
+		return
+	}
+	delay = delay/2 + time.Duration(rand.Int63n(int64(delay/2)+1))
+	if err := aws.SleepWithContext(r.Context(), delay); err != nil {
+		r.Error = err
+	}
+}
+
+func (b *adaptiveBackoff) observe(r *request.Request) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	if !isThrottled(r) {
+		if r.Error == nil && b.delay > 0 {
+			b.delay = b.delay * 3 / 4
+			if b.delay < minBackoffDelay {
+				b.delay = 0
+			}
+		}
+		return
+	}
+
+	if b.delay == 0 {
+		fmt.Fprintln(os.Stderr, "S3 is asking for fewer requests, slowing down.")
+		b.delay = minBackoffDelay
+		return
+	}
+	b.delay *= 2
+	if b.delay > maxBackoffDelay {
+		b.delay = maxBackoffDelay
+	}
+}
+
+// isThrottled reports if S3 asked for less traffic, either for the whole
+// request or for any of the keys in a DeleteObjects batch.
+func isThrottled(r *request.Request) bool {
+	if r.Error != nil {
+		return request.IsErrorThrottle(r.Error) ||
+			(r.HTTPResponse != nil && r.HTTPResponse.StatusCode == http.StatusServiceUnavailable)
+	}
+	if out, ok := r.Data.(*s3.DeleteObjectsOutput); ok {
+		for _, e := range out.Errors {
+			if aws.StringValue(e.Code) == "SlowDown" {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// defaultMaxRetries is the SDK default for S3.
+const defaultMaxRetries = client.DefaultRetryerMaxNumRetries
+
+// retryPolicy sets how the SDK retries a failed request. Zero delays keep the
+// SDK defaults, which wait longer after a throttle than after other errors.
+type retryPolicy struct {
+	maxRetries int
+	baseDelay  time.Duration
+	maxDelay   time.Duration
+}
+
+func (p retryPolicy) applyToConfig(config *aws.Config) {
+	request.WithRetryer(config, client.DefaultRetryer{
+		NumMaxRetries:    p.maxRetries,
+		MinRetryDelay:    p.baseDelay,
+		MinThrottleDelay: p.baseDelay,
+		MaxRetryDelay:    p.maxDelay,
+		MaxThrottleDelay: p.maxDelay,
+	})
+}
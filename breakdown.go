@@ -0,0 +1,128 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+// rootPrefix is used to group keys that have no "/" in them.
+const rootPrefix = "(root)"
+
+type prefixStats struct {
+	Prefix string
+	Count  int64
+	Size   int64
+}
+
+type prefixBreakdown []*prefixStats
+
+// breakdown groups the objects and delete markers by the segment of their key
+// before the first "/". The result is sorted by count, largest first.
+func (objList *objectList) breakdown() prefixBreakdown {
+	tally := prefixTally{}
+	for _, obj := range objList.Objects {
+		tally.add(obj.Key, obj.Size)
+	}
+	for _, dm := range objList.DeleteMarkers {
+		tally.add(aws.StringValue(dm.Key), 0)
+	}
+	return tally.breakdown()
+}
+
+// prefixTally adds up the count and size under each top-level prefix.
+type prefixTally map[string]*prefixStats
+
+func (stats prefixTally) add(key string, size int64) {
+	prefix := topLevelPrefix(key)
+	s, ok := stats[prefix]
+	if !ok {
+		s = &prefixStats{Prefix: prefix}
+		stats[prefix] = s
+	}
+	s.Count++
+	s.Size += size
+}
+
+func (stats prefixTally) breakdown() prefixBreakdown {
+	result := make(prefixBreakdown, 0, len(stats))
+	for _, s := range stats {
+		result = append(result, s)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Count != result[j].Count {
+			return result[i].Count > result[j].Count
+		}
+		return result[i].Prefix < result[j].Prefix
+	})
+	return result
+}
+
+func topLevelPrefix(key string) string {
+	if i := strings.Index(key, "/"); i >= 0 {
+		return key[:i+1]
+	}
+	return rootPrefix
+}
+
+func (b prefixBreakdown) toTable() string {
+	var total int64
+	for _, s := range b {
+		total += s.Count
+	}
+
+	buf := &bytes.Buffer{}
+	w := tabwriter.NewWriter(buf, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "PREFIX\tCOUNT\tPERCENT\tSIZE")
+	for _, s := range b {
+		percent := 0.0
+		if total > 0 {
+			percent = float64(s.Count) / float64(total) * 100
+		}
+		fmt.Fprintf(w, "%s\t%d\t%.1f%%\t%s\n", s.Prefix, s.Count, percent, humanBytes(s.Size))
+	}
+	w.Flush()
+	return buf.String()
+}
+
+// humanBytes formats a byte count using binary units, eg 1.5 GiB.
+func humanBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
+
+// summarizeByPrefix is used by -dry-run-summary. Like countObjects it keeps
+// nothing from each page, only the totals for each top-level prefix.
+func summarizeByPrefix(awsSession *session.Session, bucket string, opts listOptions, skipDeleteMarkers bool) (prefixBreakdown, listCount, error) {
+	tally := prefixTally{}
+	count := listCount{}
+	err := walkListing(awsSession, bucket, opts, skipDeleteMarkers,
+		func(obj object) error {
+			tally.add(obj.Key, obj.Size)
+			count.Versions++
+			count.TotalSize += obj.Size
+			return nil
+		},
+		func(dm *s3.DeleteMarkerEntry) error {
+			tally.add(aws.StringValue(dm.Key), 0)
+			count.DeleteMarkers++
+			return nil
+		},
+	)
+	count.Total = count.Versions + count.DeleteMarkers
+	return tally.breakdown(), count, err
+}
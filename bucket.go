@@ -0,0 +1,828 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+// runner empties the buckets of a run. It holds the flags and everything
+// made from them that the buckets share.
+type runner struct {
+	*runFlags
+
+	listOpts listOptions
+	// prefixes are the prefixes listed, from -prefix and -prefix-file.
+	prefixes []string
+	// keepDeleteMarkers is set when delete markers are left out of the
+	// listing.
+	keepDeleteMarkers bool
+	filterTag         *s3.Tag
+	deleteTag         *s3.Tag
+	lineTemplate      *template.Template
+	sseKey            sseCustomerKey
+	// streamListing writes -format ndjson and template as the bucket is
+	// listed.
+	streamListing bool
+	// streaming lists and deletes a page at a time.
+	streaming     bool
+	awsOptions    sessionOptions
+	bucketRegions map[string]string
+
+	logger   *eventLogger
+	jsonLogs bool
+	// console gets the messages, notices the messages that always go to
+	// stderr and info the ones -quiet drops. out gets the output that was
+	// asked for, like -stats.
+	console, notices, info, out io.Writer
+	statuses                    *statusBoard
+	// deleteRequestSlots is shared by every bucket so running several at
+	// once can not send more than -max-delete-requests at a time.
+	deleteRequestSlots chan struct{}
+}
+
+// emptyBuckets works on each bucket, -bucket-concurrency at a time. Each
+// bucket has its own session and report, so they can be worked on at the
+// same time. The exit code is the first failure in the order the buckets
+// were given, whatever order they finish in.
+func (r *runner) emptyBuckets(buckets []string, reports []*runReport) (exitCode int) {
+	process, action := r.emptyBucket, "Emptying"
+	if *r.listOnly {
+		process, action = r.listBucket, "Listing"
+	}
+	codes := make([]int, len(buckets))
+	work := make(chan int)
+	wg := sync.WaitGroup{}
+	for w := 0; w < *r.bucketConcurrency; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range work {
+				if len(buckets) > 1 && !*r.quiet {
+					fmt.Fprintf(r.notices, "%s bucket '%s'\n", action, buckets[i])
+				}
+				r.logger.info("bucket started", field("bucket", buckets[i]), field("action", strings.ToLower(action)))
+				codes[i] = process(buckets[i], reports[i])
+				logReport(r.logger, reports[i])
+				if *r.notifyTopic != "" {
+					r.notify(reports[i])
+				}
+			}
+		}()
+	}
+	for i := range buckets {
+		work <- i
+	}
+	close(work)
+	wg.Wait()
+	for _, code := range codes {
+		if code != 0 && exitCode == 0 {
+			exitCode = code
+		}
+	}
+	if len(buckets) > 1 && !*r.quiet {
+		fmt.Fprint(r.notices, bucketSummaryTable(reports))
+	}
+	return exitCode
+}
+
+// newDeleteOptions gathers the delete flags. The returned stats are nil
+// unless -stats is used. failed, which may be nil, is told about every batch
+// so it can collect the objects that were not deleted.
+func (r *runner) newDeleteOptions(bucket string, progress *progressTracker, failed *failedCollector) (deleteOptions, *batchStats) {
+	observers := multiObserver{}
+	if r.logger != nil {
+		observers = append(observers, logObserver{log: r.logger, bucket: bucket})
+	}
+	if *r.streamResults {
+		observers = append(observers, newStreamObserver(os.Stdout))
+	} else {
+		observers = append(observers, consoleObserver{w: r.info, tagging: *r.tagForDeletion})
+	}
+	if r.verbose >= verboseInfo {
+		observers = append(observers, verboseObserver{w: r.notices})
+	}
+	var stats *batchStats
+	if *r.stats {
+		stats = newBatchStats()
+		observers = append(observers, stats)
+	}
+	observers = append(observers, progress)
+	if failed != nil {
+		observers = append(observers, failed)
+	}
+
+	return deleteOptions{
+		batchSize:       *r.batchSize,
+		maxBatchBytes:   *r.maxBatchBytes,
+		observer:        observers,
+		tag:             r.deleteTag,
+		simulate:        *r.simulate,
+		simulateLatency: *r.simulateLatency,
+		maxErrorDetails: *r.maxErrorDetails,
+		largestFirst:    *r.largestFirst,
+		singleDelete:    *r.singleDelete,
+		archiveBucket:   *r.archiveBucket,
+		requestSlots:    r.deleteRequestSlots,
+		markDeleted:     *r.currentOnly,
+		concurrency:     *r.concurrency,
+		quietDelete:     *r.quietDelete,
+		info:            r.info,
+	}, stats
+}
+
+// newFailedCollector returns nil unless -failed-output is used.
+func (r *runner) newFailedCollector(bucket string) *failedCollector {
+	if *r.failedOutput == "" {
+		return nil
+	}
+	return newFailedCollector(bucket)
+}
+
+func (r *runner) writeFailedOutput(failed *failedCollector, report *runReport) {
+	if failed == nil {
+		return
+	}
+	written, err := failed.write(*r.failedOutput)
+	if err != nil {
+		fmt.Fprintf(r.console, "There was an error writing the failed objects to %s. Error: %s\n", *r.failedOutput, err)
+		report.addError(err)
+		return
+	}
+	if written {
+		fmt.Fprintf(r.console, "%d objects that could not be deleted were written to %s, retry them with -from-manifest.\n", failed.list.ObjectCount, *r.failedOutput)
+	}
+}
+
+// notify is best effort, a failure is only a warning.
+func (r *runner) notify(report *runReport) {
+	awsSession, err := setupAwsSession(r.awsOptions)
+	if err == nil {
+		err = publishNotification(awsSession, *r.notifyTopic, report)
+	}
+	if err != nil {
+		fmt.Fprintf(r.notices, "WARNING: could not publish the notification to %s. Error: %s\n", *r.notifyTopic, err)
+	}
+}
+
+func (r *runner) publishRunMetrics(awsSession *session.Session, report *runReport) {
+	if err := publishMetrics(awsSession, *r.metricsNamespace, report); err != nil {
+		fmt.Fprintf(r.notices, "WARNING: could not publish CloudWatch metrics. Error: %s\n", err)
+	}
+}
+
+// connectBucket sets up the session for a bucket and runs the preflight
+// checks against it. A non zero exit code means the bucket can not be used.
+func (r *runner) connectBucket(bucket string, report *runReport) (*session.Session, int) {
+	awsSession, err := setupAwsSession(r.awsOptions)
+	if err != nil {
+		fmt.Fprintf(r.console, "There was an error getting your AWS Creds. Error: %s\n", err)
+		return nil, 1
+	}
+	awsSession.Config.CredentialsChainVerboseErrors = aws.Bool(true)
+	region, mapped := r.bucketRegions[bucket]
+	if mapped {
+		awsSession.Config.Region = aws.String(region)
+	}
+	report.Region = aws.StringValue(awsSession.Config.Region)
+
+	// With -endpoint-url the credentials are usually for the S3 compatible
+	// store rather than AWS, so STS can not check them and the first S3
+	// request does instead.
+	if *r.endpointURL == "" {
+		identity, err := verifyCredentials(awsSession)
+		if err != nil {
+			report.addError(err)
+			if hint := credentialsErrorHint(err, *r.profile); hint != "" {
+				fmt.Fprintln(r.console, hint)
+				return nil, exitCodeFor(err)
+			}
+			fmt.Fprintf(r.console, "There was an error verifying your AWS Creds. Error: %s\n", err)
+			return nil, exitCodeFor(err)
+		}
+		if r.verbose >= verboseInfo {
+			fmt.Fprintf(r.notices, "Using AWS account %s as %s\n", aws.StringValue(identity.Account), aws.StringValue(identity.Arn))
+		}
+	}
+
+	if !*r.noRegionAutodetect && !mapped {
+		detected, err := detectBucketRegion(awsSession, bucket)
+		if err != nil {
+			fmt.Fprintf(r.notices, "Could not detect the region of bucket '%s', using %s. Error: %s\n", bucket, aws.StringValue(awsSession.Config.Region), err)
+		} else {
+			if detected != aws.StringValue(awsSession.Config.Region) {
+				fmt.Fprintf(r.info, "Bucket '%s' is in region %s, overriding %s\n", bucket, detected, aws.StringValue(awsSession.Config.Region))
+				awsSession.Config.Region = aws.String(detected)
+				report.Region = detected
+			}
+			if r.verbose >= verboseInfo {
+				fmt.Fprintf(r.notices, "Detected bucket region %s\n", detected)
+			}
+		}
+	}
+
+	if err := checkBucket(awsSession, bucket); err != nil {
+		report.addError(err)
+		fmt.Fprintf(r.console, "Preflight check failed: %s.\n", err)
+		return nil, 1
+	}
+
+	if !*r.ignoreLifecycle {
+		rules, err := expiringLifecycleRules(awsSession, bucket)
+		if err != nil {
+			if r.verbose >= verboseInfo {
+				fmt.Fprintf(r.notices, "Could not read the lifecycle configuration of bucket '%s'. Error: %s\n", bucket, err)
+			}
+		} else if len(rules) > 0 {
+			fmt.Fprintf(r.notices, "WARNING: bucket '%s' has lifecycle rules that expire objects (%s). Lifecycle may already be cleaning it up. Use -ignore-lifecycle to hide this warning.\n", bucket, strings.Join(rules, ", "))
+		}
+	}
+
+	return awsSession, 0
+}
+
+// listFailed reports an error listing the bucket.
+func (r *runner) listFailed(bucket string, err error, report *runReport) int {
+	fmt.Fprintf(r.console, "There was an error listing the objects for your specified bucket '%s'.\nError: %s\n", bucket, err)
+	printRegionHint(r.console, err)
+	report.addError(err)
+	return exitCodeFor(err)
+}
+
+// countBucket prints the totals for -format count without holding the
+// listing in memory.
+func (r *runner) countBucket(awsSession *session.Session, bucket string, report *runReport) int {
+	count, err := countObjects(awsSession, bucket, r.listOpts, r.keepDeleteMarkers)
+	if err != nil {
+		return r.listFailed(bucket, err, report)
+	}
+	fmt.Println(count)
+	return 0
+}
+
+// summaryBucket prints the totals under each prefix for -dry-run-summary
+// without holding the listing in memory.
+func (r *runner) summaryBucket(awsSession *session.Session, bucket string, report *runReport) int {
+	breakdown, count, err := summarizeByPrefix(awsSession, bucket, r.listOpts, r.keepDeleteMarkers)
+	if err != nil {
+		return r.listFailed(bucket, err, report)
+	}
+	fmt.Print(breakdown.toTable())
+	fmt.Printf("\nTotal: %d versions, %d delete markers, %s\n", count.Versions, count.DeleteMarkers, humanBytes(count.TotalSize))
+	return 0
+}
+
+// lineWriter writes a line of -format ndjson or template to stdout.
+func (r *runner) lineWriter() func(listingLine) error {
+	if r.lineTemplate != nil {
+		return templateWriter(os.Stdout, r.lineTemplate)
+	}
+	return ndjsonWriter(os.Stdout)
+}
+
+// streamBucket writes each version to stdout as it is listed for -format
+// ndjson and template.
+func (r *runner) streamBucket(awsSession *session.Session, bucket string, report *runReport) int {
+	err := streamLines(awsSession, bucket, r.listOpts, r.keepDeleteMarkers, r.lineWriter())
+	var listErr *ListError
+	if errors.As(err, &listErr) {
+		return r.listFailed(bucket, err, report)
+	}
+	if err != nil {
+		fmt.Fprintf(r.notices, "There was an error writing the listing. Error: %s\n", err)
+		report.addError(err)
+		return exitCodeFor(err)
+	}
+	return 0
+}
+
+// printList prints the whole listing to stdout in -format.
+func (r *runner) printList(list *objectList) error {
+	if r.lineTemplate != nil {
+		return list.lines(r.lineWriter())
+	}
+	fmt.Println(list.toString(*r.format))
+	return nil
+}
+
+// writeList writes the listing to -output.
+func (r *runner) writeList(list *objectList, report *runReport) int {
+	files, err := writeOutput(*r.output, *r.format, list, outputOptions{
+		chunkSize: *r.outputChunkSize,
+		compress:  *r.outputCompress,
+		template:  r.lineTemplate,
+	})
+	if err != nil {
+		fmt.Fprintf(r.console, "There was an error writing the listing to %s. Error: %s\n", *r.output, err)
+		report.addError(err)
+		return 1
+	}
+	if !*r.quiet {
+		fmt.Fprintf(r.notices, "Wrote the listing of %d objects to %s\n", list.ObjectCount, strings.Join(files, ", "))
+	}
+	return 0
+}
+
+// listBucket is used by -list-only. It lists the bucket and writes the
+// listing, and must never call anything that deletes or changes objects.
+func (r *runner) listBucket(bucket string, report *runReport) (exitCode int) {
+	defer func() { report.finish(exitCode) }()
+
+	awsSession, code := r.connectBucket(bucket, report)
+	if code != 0 {
+		return code
+	}
+	if *r.format == "count" {
+		return r.countBucket(awsSession, bucket, report)
+	}
+	if r.streamListing {
+		return r.streamBucket(awsSession, bucket, report)
+	}
+
+	list, err := listObjects(awsSession, bucket, r.listOpts)
+	if errors.Is(err, ErrEmptyBucket) || errors.Is(err, ErrNoMatchingObjects) {
+		// An empty inventory is still worth writing.
+		list, err = newObjectList(), nil
+		list.Bucket = bucket
+		list.GeneratedAt = aws.Time(time.Now().UTC())
+	}
+	if err != nil {
+		return r.listFailed(bucket, err, report)
+	}
+	if r.keepDeleteMarkers {
+		list.dropDeleteMarkers()
+	}
+	if r.filterTag != nil {
+		_, errs := keepOnlyTagged(awsSession, bucket, list, r.filterTag, *r.tagConcurrency)
+		for _, err := range errs {
+			fmt.Fprintf(r.notices, "Could not read the tags of %s, it is left out of the listing\n", err)
+			report.addError(err)
+		}
+	}
+	if *r.keepVersions > 0 {
+		list.keepNewestVersions(*r.keepVersions)
+		list.keepLatestDeleteMarkers()
+	}
+	if *r.sort {
+		list.sortByKey()
+	}
+
+	if *r.output != "" {
+		return r.writeList(list, report)
+	}
+	if *r.showSummaryOnly {
+		fmt.Println(list.summary().toString(*r.format))
+	} else if err := r.printList(list); err != nil {
+		fmt.Fprintf(r.console, "There was an error writing the listing. Error: %s\n", err)
+		report.addError(err)
+		return 1
+	}
+	return 0
+}
+
+// showProgress shows the progress of the bucket with -progress until the
+// returned stop is called. It is shown while listing and again while
+// deleting, and stopped in between so it does not get mixed up with anything
+// else printed. Several buckets at once can not share a redrawn line.
+func (r *runner) showProgress(bucket string, progress *progressTracker) (stop func()) {
+	if !*r.progress {
+		return func() {}
+	}
+	return showProgress(r.notices, bucket, progress, !r.jsonLogs && isTerminal(os.Stderr) && *r.bucketConcurrency == 1)
+}
+
+// bucketListOptions are the list options with the observers of the bucket.
+func (r *runner) bucketListOptions(bucket string, progress *progressTracker) listOptions {
+	opts := r.listOpts
+	observers := multiObserver{}
+	if *r.progress {
+		observers = append(observers, progress)
+	}
+	if r.logger != nil {
+		observers = append(observers, logObserver{log: r.logger, bucket: bucket})
+	}
+	if len(observers) > 0 {
+		opts.observer = observers
+	}
+	return opts
+}
+
+// emptyBucket does everything for a single bucket, from checking the
+// credentials through to deleting. Its exit code is recorded in report.
+func (r *runner) emptyBucket(bucket string, report *runReport) (exitCode int) {
+	defer func() { report.finish(exitCode) }()
+
+	// The bucket is on the status board from the start, so the status signal
+	// reports on it while listing too.
+	progress := newProgressTracker()
+	defer r.statuses.track(bucket, progress)()
+
+	awsSession, code := r.connectBucket(bucket, report)
+	if code != 0 {
+		return code
+	}
+	if *r.dryRunSummary {
+		return r.summaryBucket(awsSession, bucket, report)
+	}
+	if *r.format == "count" {
+		return r.countBucket(awsSession, bucket, report)
+	}
+	if r.streamListing {
+		return r.streamBucket(awsSession, bucket, report)
+	}
+
+	uploads, code := r.abortUploads(awsSession, bucket, report)
+	if code != 0 {
+		return code
+	}
+	if r.streaming {
+		return r.deleteWhileListing(awsSession, bucket, progress, report)
+	}
+
+	listing, code := r.listToDelete(awsSession, bucket, progress, uploads, report)
+	if listing == nil {
+		return code
+	}
+	list := listing.list
+
+	// The cap is checked as soon as the listing is known, so nobody is asked
+	// to select, review or validate objects that are then refused.
+	if listing.overCap && !*r.dryRun {
+		if !*r.force {
+			fmt.Fprintf(r.console, "Found %d objects which is above -max-objects %d. Refusing to delete, use -force to override.\n", list.ObjectCount, *r.maxObjects)
+			return 1
+		}
+		fmt.Fprintf(r.console, "Found %d objects which is above -max-objects %d. Continuing because -force was given.\n", list.ObjectCount, *r.maxObjects)
+	}
+
+	if *r.selectObjects {
+		if list, code = r.selectFrom(list, report); list == nil {
+			return code
+		}
+		listing.list = list
+	}
+
+	if *r.checkLocks {
+		for _, err := range checkLocks(r.info, awsSession, bucket, list, *r.checkLocksRate, r.sseKey) {
+			fmt.Fprintf(r.notices, "Could not check lock status of %s\n", err)
+		}
+	}
+	if *r.sort {
+		list.sortByKey()
+	}
+	if *r.checksumVerify {
+		listing.metadataIssues = metadataProblems(list)
+		for _, problem := range listing.metadataIssues {
+			fmt.Fprintf(r.notices, "WARNING: %s\n", problem)
+		}
+	}
+
+	if *r.output != "" {
+		if code := r.writeList(list, report); code != 0 {
+			return code
+		}
+	} else if *r.showSummaryOnly {
+		fmt.Println(list.summary().toString(*r.format))
+	} else if *r.dryRun || *r.showObjects {
+		if err := r.printList(list); err != nil {
+			fmt.Fprintf(r.console, "There was an error writing the listing. Error: %s\n", err)
+			report.addError(err)
+			return 1
+		}
+	}
+
+	if *r.dryRun {
+		r.printDryRun(listing)
+		return 0
+	}
+
+	// The review is of the exact list that is then deleted, so nothing that
+	// turned up after it was shown can be deleted.
+	if *r.reviewThenDelete {
+		if ok, code := r.review(bucket, list, report); !ok {
+			return code
+		}
+	}
+
+	// A validation run deletes a small sample for real and stops there, so
+	// permission, lock and MFA problems show up before the long run.
+	if *r.validateDelete > 0 {
+		return r.deleteSample(awsSession, bucket, list, progress, report)
+	}
+	return r.deleteList(awsSession, bucket, list, progress, report)
+}
+
+// abortUploads lists the incomplete multipart uploads with
+// -abort-multipart-uploads and aborts them, unless it is a dry run or a
+// simulation. Uploads are aborted first as they are not affected by anything
+// done to the objects, and they should go even if there are no objects. In a
+// dry run they are added to the listing instead.
+func (r *runner) abortUploads(awsSession *session.Session, bucket string, report *runReport) ([]multipartUpload, int) {
+	if !*r.abortMultipartUploads {
+		return nil, 0
+	}
+	uploads, err := listMultipartUploads(awsSession, bucket, r.listOpts)
+	if err != nil {
+		fmt.Fprintf(r.console, "There was an error listing the multipart uploads for bucket '%s'.\nError: %s\n", bucket, err)
+		report.addError(err)
+		return nil, 1
+	}
+	if !*r.dryRun && !*r.simulate {
+		aborted, errs := abortMultipartUploads(awsSession, bucket, uploads)
+		report.MultipartUploadsAborted = aborted
+		fmt.Fprintf(r.info, "Aborted %d of %d incomplete multipart uploads\n", aborted, len(uploads))
+		for _, e := range errs {
+			fmt.Fprintln(r.console, e)
+			report.addError(errors.New(e))
+		}
+	}
+	return uploads, 0
+}
+
+// deleteWhileListing is used by -delete-while-listing and -resume-file, it
+// deletes each page as it is listed.
+func (r *runner) deleteWhileListing(awsSession *session.Session, bucket string, progress *progressTracker, report *runReport) int {
+	if !countdown(r.notices, fmt.Sprintf("Deleting objects from %s", bucket), *r.delay) {
+		return 1
+	}
+	stopProgress := r.showProgress(bucket, progress)
+	if *r.emitMetrics {
+		defer r.publishRunMetrics(awsSession, report)
+	}
+	failed := r.newFailedCollector(bucket)
+	opts, stats := r.newDeleteOptions(bucket, progress, failed)
+	resume := resumeOptions{
+		path:              *r.resumeFile,
+		skipDeleteMarkers: r.keepDeleteMarkers,
+		maxInFlight:       *r.maxInFlight,
+	}
+	result, err := deleteResumable(awsSession, bucket, r.bucketListOptions(bucket, progress), opts, resume)
+	stopProgress()
+	printDeleteResult(r.console, result, err, report)
+	r.writeFailedOutput(failed, report)
+	if !*r.simulate {
+		fmt.Fprint(r.info, report.summary(*r.tagForDeletion))
+	}
+	var deleteErr *DeleteError
+	if err != nil && !errors.As(err, &deleteErr) {
+		fmt.Fprintf(r.console, "There was an error emptying bucket '%s'. Error: %s\n", bucket, err)
+		printRegionHint(r.console, err)
+		report.addError(err)
+	}
+	if stats != nil {
+		fmt.Fprint(r.out, stats)
+	}
+	if *r.clearConfig && err == nil {
+		printConfigRemovals(r.console, r.info, clearBucketConfig(awsSession, bucket), report)
+	}
+	return exitCodeFor(err)
+}
+
+// bucketListing is what is to be deleted from a bucket, along with what was
+// left out of it for a dry run to report.
+type bucketListing struct {
+	list             *objectList
+	retainedMarkers  int64
+	untagged         int64
+	retainedVersions int64
+	currentMarkers   int64
+	metadataIssues   []string
+	// overCap is set when more than -max-objects were found.
+	overCap bool
+}
+
+// listToDelete lists the bucket, or loads the manifest, and leaves out what
+// is being kept. The listing is nil when there is nothing more to do for the
+// bucket, with the exit code to use.
+func (r *runner) listToDelete(awsSession *session.Session, bucket string, progress *progressTracker, uploads []multipartUpload, report *runReport) (*bucketListing, int) {
+	var list *objectList
+	var err error
+	if *r.fromManifest != "" {
+		list, err = loadManifest(*r.fromManifest, bucket, *r.manifestMaxAge)
+		if err != nil {
+			fmt.Fprintf(r.console, "There was an error loading the manifest. Error: %s\n", err)
+			report.addError(err)
+			return nil, 1
+		}
+	} else {
+		stopProgress := r.showProgress(bucket, progress)
+		list, err = listObjects(awsSession, bucket, r.bucketListOptions(bucket, progress))
+		stopProgress()
+	}
+	if *r.dryRun && len(uploads) > 0 && (errors.Is(err, ErrEmptyBucket) || errors.Is(err, ErrNoMatchingObjects)) {
+		// There are no objects, but the uploads are still worth showing.
+		list, err = newObjectList(), nil
+	}
+	if *r.dryRun {
+		list.MultipartUploads = uploads
+	}
+	if errors.Is(err, ErrEmptyBucket) {
+		return nil, r.nothingToDo(fmt.Sprintf("Bucket '%s' is already empty, nothing to do.", bucket), err, report)
+	}
+	if errors.Is(err, ErrNoMatchingObjects) {
+		return nil, r.nothingToDo(fmt.Sprintf("Bucket '%s' has objects but none matched the prefixes or filters given, nothing to do.", bucket), err, report)
+	}
+	if err != nil {
+		return nil, r.listFailed(bucket, err, report)
+	}
+
+	listing := &bucketListing{list: list}
+	nothingLeft := func() bool {
+		return list.ObjectCount == 0 && len(list.MultipartUploads) == 0
+	}
+	if r.keepDeleteMarkers {
+		listing.retainedMarkers = list.dropDeleteMarkers()
+		if nothingLeft() {
+			return nil, r.nothingToDo(fmt.Sprintf("Only delete markers were found in bucket '%s' and they are being kept, nothing to do.", bucket), ErrNoMatchingObjects, report)
+		}
+	}
+	if r.filterTag != nil {
+		var errs []error
+		listing.untagged, errs = keepOnlyTagged(awsSession, bucket, list, r.filterTag, *r.tagConcurrency)
+		for _, err := range errs {
+			fmt.Fprintf(r.notices, "Could not read the tags of %s, it will not be deleted\n", err)
+			report.addError(err)
+		}
+		if nothingLeft() {
+			return nil, r.nothingToDo(fmt.Sprintf("No versions in bucket '%s' have the tag %s, nothing to do.", bucket, *r.tag), ErrNoMatchingObjects, report)
+		}
+	}
+	if *r.keepVersions > 0 {
+		listing.retainedVersions = list.keepNewestVersions(*r.keepVersions)
+		listing.currentMarkers = list.keepLatestDeleteMarkers()
+		if nothingLeft() {
+			return nil, r.nothingToDo(fmt.Sprintf("Every version in bucket '%s' is within the newest %d of its key and is being kept, nothing to do.", bucket, *r.keepVersions), ErrNoMatchingObjects, report)
+		}
+	}
+	listing.overCap = *r.maxObjects > 0 && list.ObjectCount > *r.maxObjects
+	return listing, 0
+}
+
+// nothingToDo says why there is nothing to delete. It is a failure, with
+// the exit code for err, unless -no-fail-if-empty is used.
+func (r *runner) nothingToDo(message string, err error, report *runReport) int {
+	fmt.Fprintln(r.info, message)
+	if *r.noFailIfEmpty {
+		return 0
+	}
+	report.addError(err)
+	return exitCodeFor(err)
+}
+
+// selectFrom lets the user pick what to delete with -select. The list is nil
+// if nothing is to be deleted, with the exit code to use.
+func (r *runner) selectFrom(list *objectList, report *runReport) (*objectList, int) {
+	selected, ok, err := interactiveSelect(list)
+	if err != nil {
+		fmt.Fprintf(r.console, "There was an error selecting objects. Error: %s\n", err)
+		report.addError(err)
+		return nil, 1
+	}
+	if !ok {
+		fmt.Fprintln(r.console, "Selection aborted, nothing will be deleted.")
+		return nil, 0
+	}
+	if selected.ObjectCount == 0 {
+		fmt.Fprintln(r.console, "No objects were selected, nothing will be deleted.")
+		return nil, 0
+	}
+	return selected, 0
+}
+
+// printDryRun says what a dry run left out of the listing, and what a real
+// run would do differently.
+func (r *runner) printDryRun(listing *bucketListing) {
+	list := listing.list
+	if *r.breakdown {
+		fmt.Print(list.breakdown().toTable())
+	}
+	if r.keepDeleteMarkers {
+		fmt.Fprintf(r.console, "%d delete markers are being kept and are not in the listing.\n", listing.retainedMarkers)
+	}
+	if *r.currentOnly {
+		fmt.Fprintln(r.console, "Only current versions are in the listing. They are not removed, a delete marker is put on top of each key.")
+	}
+	if *r.storageClass != "" {
+		fmt.Fprintf(r.console, "Only versions in storage class %s are in the listing.\n", *r.storageClass)
+	}
+	if *r.checksumVerify {
+		fmt.Fprintf(r.console, "%d versions have a missing or unexpected ETag or owner.\n", len(listing.metadataIssues))
+	}
+	if r.filterTag != nil {
+		fmt.Fprintf(r.console, "%d versions do not have the tag %s and are not in the listing.\n", listing.untagged, *r.tag)
+	}
+	if *r.keepVersions > 0 {
+		fmt.Fprintf(r.console, "%d versions are being kept as the newest %d of their key and are not in the listing.\n", listing.retainedVersions, *r.keepVersions)
+		if listing.currentMarkers > 0 {
+			fmt.Fprintf(r.console, "%d delete markers are the current version of their key and are not in the listing.\n", listing.currentMarkers)
+		}
+	}
+	if listing.overCap && !*r.force {
+		fmt.Fprintf(r.console, "Found %d objects which is above -max-objects %d. A real run would be blocked unless -force is used.\n", list.ObjectCount, *r.maxObjects)
+	}
+}
+
+// review shows what is about to be deleted for -review-then-delete and asks
+// for confirmation, unless -yes is used. ok is false when nothing is to be
+// deleted, with the exit code to use.
+func (r *runner) review(bucket string, list *objectList, report *runReport) (ok bool, exitCode int) {
+	printReview(os.Stdout, bucket, list)
+	if *r.yes {
+		return true, 0
+	}
+	if !isTerminal(os.Stdin) {
+		fmt.Fprintln(r.console, "-review-then-delete needs a terminal to ask for confirmation, use -yes to skip it.")
+		return false, 1
+	}
+	confirmed, err := confirmDelete(os.Stdin, os.Stdout, bucket, list.ObjectCount)
+	if err != nil {
+		fmt.Fprintf(r.console, "There was an error reading the confirmation. Error: %s\n", err)
+		report.addError(err)
+		return false, 1
+	}
+	if !confirmed {
+		fmt.Fprintln(r.console, "Not confirmed, nothing was deleted.")
+		return false, 0
+	}
+	return true, 0
+}
+
+// deleteSample deletes the first -validate-delete objects of the list for
+// real and stops there.
+func (r *runner) deleteSample(awsSession *session.Session, bucket string, list *objectList, progress *progressTracker, report *runReport) int {
+	sample := list.chunks(*r.validateDelete)[0]
+	if !countdown(r.notices, fmt.Sprintf("Deleting a sample of %d objects from %s", sample.ObjectCount, bucket), *r.delay) {
+		return 1
+	}
+	failed := r.newFailedCollector(bucket)
+	opts, _ := r.newDeleteOptions(bucket, progress, failed)
+	result, err := deleteObjects(awsSession, bucket, sample, opts)
+	printDeleteResult(r.console, result, err, report)
+	r.writeFailedOutput(failed, report)
+	deleted := result.ObjectsDeleted + result.DeleteMarkersDeleted + result.DirectoriesDeleted
+	fmt.Fprintf(r.console, "-validate-delete really deleted %d of a sample of %d objects from bucket '%s', they can not be recovered. The other %d objects found were not touched, run again without -validate-delete to delete them.\n", deleted, sample.ObjectCount, bucket, list.ObjectCount-sample.ObjectCount)
+	return exitCodeFor(err)
+}
+
+// deleteList deletes everything in the list, then clears the configuration
+// and verifies the bucket is empty if asked to.
+func (r *runner) deleteList(awsSession *session.Session, bucket string, list *objectList, progress *progressTracker, report *runReport) int {
+	if !*r.simulate && !countdown(r.notices, fmt.Sprintf("Deleting %d objects from %s", list.ObjectCount, bucket), *r.delay) {
+		return 1
+	}
+
+	if *r.emitMetrics && !*r.simulate {
+		defer r.publishRunMetrics(awsSession, report)
+	}
+
+	failed := r.newFailedCollector(bucket)
+	opts, stats := r.newDeleteOptions(bucket, progress, failed)
+	progress.setTotal(list.ObjectCount)
+	stopProgress := r.showProgress(bucket, progress)
+	result, err := deleteObjects(awsSession, bucket, list, opts)
+	stopProgress()
+	printDeleteResult(r.console, result, err, report)
+	r.writeFailedOutput(failed, report)
+	if !*r.simulate {
+		fmt.Fprint(r.info, report.summary(*r.tagForDeletion))
+	}
+	if len(list.PrefixCounts) > 0 {
+		fmt.Fprint(r.out, prefixCountTable(r.prefixes, list.PrefixCounts))
+	}
+	if stats != nil {
+		fmt.Fprint(r.out, stats)
+	}
+	if *r.simulate {
+		fmt.Fprintf(r.info, "Simulation finished, %d batches would have been sent. Nothing was deleted.\n", result.Batches)
+		return 0
+	}
+
+	// Only a bucket that was fully emptied has its configuration removed.
+	if *r.clearConfig && err == nil {
+		printConfigRemovals(r.console, r.info, clearBucketConfig(awsSession, bucket), report)
+	}
+
+	// Keys left behind by the filters, tagging or kept versions would make
+	// verification fail, so it is only done when everything under the
+	// prefixes was meant to go. A manifest is a fixed set so newer keys are
+	// expected to remain.
+	if *r.report != "" && len(r.listOpts.filters) == 0 && len(r.listOpts.versionFilters) == 0 && !*r.tagForDeletion && !*r.skipDeleteMarkers && *r.keepVersions == 0 && *r.fromManifest == "" {
+		empty, err := verifyEmpty(awsSession, bucket, r.prefixes)
+		if err != nil {
+			report.addError(fmt.Errorf("verification failed: %s", err))
+		} else {
+			report.Verified = aws.Bool(empty)
+		}
+	}
+
+	return exitCodeFor(err)
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+// configRemoval is the outcome of removing one piece of bucket
+// configuration.
+type configRemoval struct {
+	Name string
+	Err  error
+}
+
+// clearBucketConfig removes the bucket policy, lifecycle, CORS and website
+// configuration. Each is removed on its own and a failure does not stop the
+// rest. The public access block is deliberately left alone, removing it
+// could only make the bucket more exposed.
+func clearBucketConfig(awsSession *session.Session, bucket string) []configRemoval {
+	s3Handler := s3.New(awsSession)
+	b := aws.String(bucket)
+	removals := []struct {
+		name   string
+		remove func() error
+	}{
+		{"bucket policy", func() error {
+			_, err := s3Handler.DeleteBucketPolicy(&s3.DeleteBucketPolicyInput{Bucket: b})
+			return err
+		}},
+		{"lifecycle", func() error {
+			_, err := s3Handler.DeleteBucketLifecycle(&s3.DeleteBucketLifecycleInput{Bucket: b})
+			return err
+		}},
+		{"CORS", func() error {
+			_, err := s3Handler.DeleteBucketCors(&s3.DeleteBucketCorsInput{Bucket: b})
+			return err
+		}},
+		{"website", func() error {
+			_, err := s3Handler.DeleteBucketWebsite(&s3.DeleteBucketWebsiteInput{Bucket: b})
+			return err
+		}},
+	}
+
+	results := make([]configRemoval, 0, len(removals))
+	for _, r := range removals {
+		results = append(results, configRemoval{Name: r.name, Err: r.remove()})
+	}
+	return results
+}
+
+// printConfigRemovals shows the outcome of each removal and records failures
+// in the report. Failures go to w and the removals that worked to info.
+func printConfigRemovals(w, info io.Writer, results []configRemoval, report *runReport) {
+	for _, r := range results {
+		if r.Err != nil {
+			fmt.Fprintf(w, "Could not remove the %s. Error: %s\n", r.Name, r.Err)
+			report.addError(fmt.Errorf("removing the %s failed: %s", r.Name, r.Err))
+			continue
+		}
+		fmt.Fprintf(info, "Removed the %s\n", r.Name)
+		report.ConfigCleared = append(report.ConfigCleared, r.Name)
+	}
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// etagPattern matches a plain MD5 ETag or the ETag of a multipart upload,
+// which has the number of parts on the end, eg 9b2cf535f27731c974343645a3985328-12.
+var etagPattern = regexp.MustCompile(`^[0-9a-f]{32}(-[0-9]+)?$`)
+
+// zeroETag is an MD5 of all zeros, which S3 never gives a real object.
+var zeroETag = strings.Repeat("0", 32)
+
+// metadataProblems lists the object versions whose ETag or Owner, as filled
+// in by -checksum-verify, look wrong. Each entry says which version and why.
+func metadataProblems(list *objectList) []string {
+	problems := []string{}
+	for _, obj := range list.Objects {
+		reasons := []string{}
+		switch {
+		case obj.ETag == "":
+			reasons = append(reasons, "no ETag")
+		case strings.HasPrefix(obj.ETag, zeroETag):
+			reasons = append(reasons, "a zero ETag")
+		case !etagPattern.MatchString(obj.ETag):
+			reasons = append(reasons, fmt.Sprintf("an unexpected ETag %q", obj.ETag))
+		}
+		if obj.Owner == "" {
+			reasons = append(reasons, "no owner")
+		}
+		if len(reasons) > 0 {
+			problems = append(problems, fmt.Sprintf("%s (%s) has %s", obj.Key, obj.VersionId, strings.Join(reasons, " and ")))
+		}
+	}
+	return problems
+}
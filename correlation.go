@@ -0,0 +1,43 @@
+package main
+
+import (
+	"crypto/rand"
+	"fmt"
+	"regexp"
+
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/aws/session"
+)
+
+// correlationHeader carries the -correlation-id on every request. The id is
+// also added to the User-Agent, which CloudTrail records.
+const correlationHeader = "X-Correlation-Id"
+
+// correlationIDPattern keeps ids safe to put in a header and the User-Agent.
+var correlationIDPattern = regexp.MustCompile(`^[A-Za-z0-9._:-]{1,128}$`)
+
+// newCorrelationID makes a random version 4 UUID.
+func newCorrelationID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:]), nil
+}
+
+func validateCorrelationID(id string) error {
+	if !correlationIDPattern.MatchString(id) {
+		return fmt.Errorf("%q must be 1 to 128 letters, digits, '.', '_', ':' or '-'", id)
+	}
+	return nil
+}
+
+// addCorrelationID tags every request made with the session with the id.
+func addCorrelationID(awsSession *session.Session, id string) {
+	awsSession.Handlers.Build.PushBack(func(r *request.Request) {
+		r.HTTPRequest.Header.Set(correlationHeader, id)
+		request.AddToUserAgent(r, "correlation-id/"+id)
+	})
+}
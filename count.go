@@ -0,0 +1,260 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"strings"
+	"sync"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+// listCount is printed by -format count.
+type listCount struct {
+	Versions      int64 `json:"Versions"`
+	DeleteMarkers int64 `json:"DeleteMarkers"`
+	Total         int64 `json:"Total"`
+	TotalSize     int64 `json:"TotalSize"`
+}
+
+func (c listCount) String() string {
+	b, _ := json.Marshal(c)
+	return string(b)
+}
+
+// walkListing lists what listObjects would return, applying the same
+// prefixes, shards and filters, and hands each version and delete marker to
+// the callbacks as the pages arrive. The callbacks are called one at a time
+// even when ranges are listed in parallel, and listing stops at the first
+// error one returns. Nothing is kept from each page so memory use does not
+// grow with the size of the bucket.
+func walkListing(awsSession *session.Session, bucket string, opts listOptions, skipDeleteMarkers bool, onVersion func(object) error, onDeleteMarker func(*s3.DeleteMarkerEntry) error) error {
+	s3Handler := newListingClient(awsSession)
+	walk := &listingWalk{opts: opts, skipDeleteMarkers: skipDeleteMarkers, onVersion: onVersion, onDeleteMarker: onDeleteMarker}
+
+	ranges, concurrency := []keyRange{{}}, 1
+	switch {
+	case opts.discoverPrefixes:
+		prefix := ""
+		if len(opts.prefixes) == 1 {
+			prefix = opts.prefixes[0]
+		}
+		prefixes, top, err := discoverPrefixes(s3Handler, bucket, prefix, opts)
+		if err != nil {
+			return &ListError{Bucket: bucket, Err: err}
+		}
+		if err := walk.list(top); err != nil {
+			return err
+		}
+		ranges, concurrency = prefixRanges(prefixes), opts.prefixConcurrency
+	case len(opts.prefixes) > 0:
+		ranges, concurrency = prefixRanges(opts.prefixes), opts.prefixConcurrency
+	case opts.shards > 1:
+		ranges = shardRanges(opts.shards)
+		concurrency = len(ranges)
+	}
+	return walk.ranges(s3Handler, bucket, ranges, concurrency)
+}
+
+func prefixRanges(prefixes []string) []keyRange {
+	ranges := make([]keyRange, 0, len(prefixes))
+	for _, prefix := range prefixes {
+		ranges = append(ranges, keyRange{prefix: prefix})
+	}
+	return ranges
+}
+
+// listingWalk passes what walkListing finds to its callbacks.
+type listingWalk struct {
+	opts              listOptions
+	skipDeleteMarkers bool
+	onVersion         func(object) error
+	onDeleteMarker    func(*s3.DeleteMarkerEntry) error
+
+	lock sync.Mutex
+	// err is the first error a callback returned, nothing more is passed on
+	// once it is set.
+	err error
+}
+
+// ranges lists the ranges with up to concurrency of them at a time.
+func (w *listingWalk) ranges(s3Handler *s3.S3, bucket string, ranges []keyRange, concurrency int) error {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	work := make(chan keyRange)
+	listErrs := make(chan error, len(ranges))
+	wg := sync.WaitGroup{}
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for r := range work {
+				if err := w.walkRange(s3Handler, bucket, r); err != nil {
+					listErrs <- err
+				}
+			}
+		}()
+	}
+	for _, r := range ranges {
+		work <- r
+	}
+	close(work)
+	wg.Wait()
+	close(listErrs)
+
+	if err := <-listErrs; err != nil {
+		return &ListError{Bucket: bucket, Err: err}
+	}
+	return w.err
+}
+
+func (w *listingWalk) walkRange(s3Handler *s3.S3, bucket string, r keyRange) error {
+	input := &s3.ListObjectVersionsInput{Bucket: aws.String(bucket)}
+	if r.prefix != "" {
+		input.Prefix = aws.String(r.prefix)
+	}
+	if r.after != "" {
+		input.KeyMarker = aws.String(r.after)
+	}
+	return s3Handler.ListObjectVersionsPages(input, func(page *s3.ListObjectVersionsOutput, lastPage bool) bool {
+		if w.opts.observer != nil {
+			w.opts.observer.OnListPage(page)
+		}
+		return w.page(page, r) && !pagePastEnd(page, r)
+	})
+}
+
+// page passes on what in the page is in r and passes the filters. It
+// returns false once listing should stop.
+func (w *listingWalk) page(page *s3.ListObjectVersionsOutput, r keyRange) bool {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	if w.err != nil {
+		return false
+	}
+	for _, v := range page.Versions {
+		key := aws.StringValue(v.Key)
+		if r.pastEnd(key) || !matchesAll(w.opts.filters, key) {
+			continue
+		}
+		obj := objectFromVersion(v)
+		if len(w.opts.versionFilters) > 0 && !versionMatchesAll(w.opts.versionFilters, obj) {
+			continue
+		}
+		if w.err = w.onVersion(obj); w.err != nil {
+			return false
+		}
+	}
+	if w.skipDeleteMarkers {
+		return true
+	}
+	for _, dm := range page.DeleteMarkers {
+		key := aws.StringValue(dm.Key)
+		if r.pastEnd(key) || !matchesAll(w.opts.filters, key) || !deleteMarkerMatchesAll(w.opts.deleteMarkerFilters, dm) {
+			continue
+		}
+		if w.err = w.onDeleteMarker(dm); w.err != nil {
+			return false
+		}
+	}
+	return true
+}
+
+// list passes on a list that was already filtered, the keys -discover-prefixes
+// finds at the top level.
+func (w *listingWalk) list(objList *objectList) error {
+	for _, obj := range objList.Objects {
+		if err := w.onVersion(obj); err != nil {
+			return err
+		}
+	}
+	if w.skipDeleteMarkers {
+		return nil
+	}
+	for _, dm := range objList.DeleteMarkers {
+		if err := w.onDeleteMarker(dm); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// countObjects is the -format count fast path.
+func countObjects(awsSession *session.Session, bucket string, opts listOptions, skipDeleteMarkers bool) (listCount, error) {
+	count := listCount{}
+	err := walkListing(awsSession, bucket, opts, skipDeleteMarkers,
+		func(obj object) error {
+			count.Versions++
+			count.TotalSize += obj.Size
+			return nil
+		},
+		func(*s3.DeleteMarkerEntry) error {
+			count.DeleteMarkers++
+			return nil
+		},
+	)
+	count.Total = count.Versions + count.DeleteMarkers
+	return count, err
+}
+
+// listingLine is a line of -format ndjson or template. Object versions and
+// delete markers share the fields and are told apart by Type, as in parquet.
+type listingLine struct {
+	object
+	Type string `json:"Type"`
+}
+
+func versionLine(obj object) listingLine {
+	return listingLine{object: obj, Type: parquetTypeVersion}
+}
+
+func deleteMarkerLine(dm *s3.DeleteMarkerEntry) listingLine {
+	obj := newObject(aws.StringValue(dm.Key), aws.StringValue(dm.VersionId), 0)
+	obj.LastModified = dm.LastModified
+	obj.IsLatest = aws.BoolValue(dm.IsLatest)
+	return listingLine{object: obj, Type: parquetTypeDeleteMarker}
+}
+
+// streamLines is the fast path for -format ndjson and template. Each version
+// and delete marker is passed to write as soon as it is listed.
+func streamLines(awsSession *session.Session, bucket string, opts listOptions, skipDeleteMarkers bool, write func(listingLine) error) error {
+	return walkListing(awsSession, bucket, opts, skipDeleteMarkers,
+		func(obj object) error { return write(versionLine(obj)) },
+		func(dm *s3.DeleteMarkerEntry) error { return write(deleteMarkerLine(dm)) },
+	)
+}
+
+// lines passes every version and delete marker in the list to write, for
+// when the whole listing was needed first, eg to sort it or write it with
+// -output.
+func (objList *objectList) lines(write func(listingLine) error) error {
+	for _, obj := range objList.Objects {
+		if err := write(versionLine(obj)); err != nil {
+			return err
+		}
+	}
+	for _, dm := range objList.DeleteMarkers {
+		if err := write(deleteMarkerLine(dm)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ndjsonWriter writes each line as a JSON object.
+func ndjsonWriter(w io.Writer) func(listingLine) error {
+	enc := json.NewEncoder(w)
+	return func(line listingLine) error {
+		return enc.Encode(line)
+	}
+}
+
+func (objList *objectList) toNDJSON() string {
+	buf := &strings.Builder{}
+	objList.lines(ndjsonWriter(buf))
+	// The callers add the final newline, as they do for JSON.
+	return strings.TrimSuffix(buf.String(), "\n")
+}
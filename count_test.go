@@ -0,0 +1,91 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+)
+
+// listingServer answers ListObjectVersions for a bucket holding keys, one
+// version of each, in a single page.
+func listingServer(t *testing.T, keys []string) *session.Session {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		prefix, marker, delimiter := query.Get("prefix"), query.Get("key-marker"), query.Get("delimiter")
+		body := &strings.Builder{}
+		body.WriteString("<ListVersionsResult><IsTruncated>false</IsTruncated>")
+		seen := map[string]bool{}
+		for _, key := range keys {
+			if !strings.HasPrefix(key, prefix) || key <= marker {
+				continue
+			}
+			if i := strings.Index(key[len(prefix):], delimiter); delimiter != "" && i >= 0 {
+				common := key[:len(prefix)+i+1]
+				if !seen[common] {
+					seen[common] = true
+					fmt.Fprintf(body, "<CommonPrefixes><Prefix>%s</Prefix></CommonPrefixes>", common)
+				}
+				continue
+			}
+			fmt.Fprintf(body, "<Version><Key>%s</Key><VersionId>1</VersionId><IsLatest>true</IsLatest><Size>1</Size><LastModified>2021-01-01T00:00:00Z</LastModified></Version>", key)
+		}
+		body.WriteString("</ListVersionsResult>")
+		w.Write([]byte(body.String()))
+	}))
+	t.Cleanup(server.Close)
+
+	return session.Must(session.NewSession(&aws.Config{
+		Region:           aws.String("eu-west-1"),
+		Endpoint:         aws.String(server.URL),
+		S3ForcePathStyle: aws.Bool(true),
+		Credentials:      credentials.NewStaticCredentials("id", "secret", ""),
+		MaxRetries:       aws.Int(0),
+	}))
+}
+
+func TestCountObjectsListsLikeListObjects(t *testing.T) {
+	keys := []string{"0", "a/1", "a/b/2", "a/b/3", "c/4", "m", "z/5"}
+	awsSession := listingServer(t, keys)
+
+	tests := []struct {
+		name string
+		opts listOptions
+		want int64
+	}{
+		{"whole bucket", listOptions{}, 7},
+		{"shards", listOptions{shards: 4}, 7},
+		{"discovered prefixes", listOptions{discoverPrefixes: true, prefixConcurrency: 2}, 7},
+		{"discovered under a prefix", listOptions{prefixes: []string{"a/"}, discoverPrefixes: true}, 3},
+		{"nested prefixes", listOptions{prefixes: collapsePrefixes([]string{"a/", "a/b/", "c/"}), prefixConcurrency: 2}, 4},
+	}
+	for _, test := range tests {
+		count, err := countObjects(awsSession, "bucket", test.opts, false)
+		if err != nil {
+			t.Fatalf("%s: %s", test.name, err)
+		}
+		if count.Versions != test.want {
+			t.Errorf("%s: counted %d versions, want %d", test.name, count.Versions, test.want)
+		}
+		list, err := listObjects(awsSession, "bucket", test.opts)
+		if err != nil {
+			t.Fatalf("%s: %s", test.name, err)
+		}
+		if list.ObjectCount != count.Versions {
+			t.Errorf("%s: listed %d versions but counted %d", test.name, list.ObjectCount, count.Versions)
+		}
+	}
+}
+
+func TestCollapsePrefixes(t *testing.T) {
+	got := collapsePrefixes([]string{"a/b/", "c/", "a/", "c/", "a/bc", "d"})
+	want := []string{"c/", "a/", "d"}
+	if !sameStrings(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
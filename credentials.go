@@ -0,0 +1,116 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/credentials/ec2rolecreds"
+	"github.com/aws/aws-sdk-go/aws/credentials/stscreds"
+	"github.com/aws/aws-sdk-go/aws/defaults"
+	"github.com/aws/aws-sdk-go/aws/session"
+)
+
+// credentialSources are the values accepted by -credential-source.
+var credentialSources = []string{"default", "env", "profile", "ec2-instance", "ecs-container", "web-identity"}
+
+// credentialsFromSource builds the credentials for a single named source. It
+// returns nil for "default" and "profile" as the session already resolves
+// those itself.
+func credentialsFromSource(awsSession *session.Session, source string) (*credentials.Credentials, error) {
+	switch source {
+	case "env":
+		return credentials.NewEnvCredentials(), nil
+	case "ec2-instance":
+		return ec2rolecreds.NewCredentials(awsSession), nil
+	case "ecs-container":
+		if os.Getenv("AWS_CONTAINER_CREDENTIALS_RELATIVE_URI") == "" && os.Getenv("AWS_CONTAINER_CREDENTIALS_FULL_URI") == "" {
+			return nil, fmt.Errorf("ecs-container credentials need AWS_CONTAINER_CREDENTIALS_RELATIVE_URI or AWS_CONTAINER_CREDENTIALS_FULL_URI to be set")
+		}
+		return credentials.NewCredentials(defaults.RemoteCredProvider(*awsSession.Config, awsSession.Handlers)), nil
+	case "web-identity":
+		roleARN := os.Getenv("AWS_ROLE_ARN")
+		tokenFile := os.Getenv("AWS_WEB_IDENTITY_TOKEN_FILE")
+		if roleARN == "" || tokenFile == "" {
+			return nil, fmt.Errorf("web-identity credentials need AWS_ROLE_ARN and AWS_WEB_IDENTITY_TOKEN_FILE to be set")
+		}
+		return stscreds.NewWebIdentityCredentials(awsSession, roleARN, os.Getenv("AWS_ROLE_SESSION_NAME"), tokenFile), nil
+	}
+	return nil, nil
+}
+
+// staticCredentials holds explicitly supplied keys. The JSON field names match
+// the "Credentials" block returned by `aws sts assume-role` so that output can
+// be used as a credentials file directly.
+type staticCredentials struct {
+	AccessKeyId     string `json:"AccessKeyId"`
+	SecretAccessKey string `json:"SecretAccessKey"`
+	SessionToken    string `json:"SessionToken"`
+}
+
+func (c staticCredentials) isSet() bool {
+	return c.AccessKeyId != "" || c.SecretAccessKey != "" || c.SessionToken != ""
+}
+
+func (c staticCredentials) validate() error {
+	if c.AccessKeyId == "" || c.SecretAccessKey == "" {
+		return fmt.Errorf("both an access key id and a secret access key are required")
+	}
+	return nil
+}
+
+func (c staticCredentials) toCredentials() *credentials.Credentials {
+	return credentials.NewStaticCredentials(c.AccessKeyId, c.SecretAccessKey, c.SessionToken)
+}
+
+// readCredentialsFile reads static credentials from a JSON file. The keys can
+// either be at the top level or nested under "Credentials".
+func readCredentialsFile(path string) (staticCredentials, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return staticCredentials{}, err
+	}
+
+	wrapped := struct {
+		staticCredentials
+		Credentials *staticCredentials `json:"Credentials"`
+	}{}
+	if err := json.Unmarshal(b, &wrapped); err != nil {
+		return staticCredentials{}, fmt.Errorf("could not parse %s: %s", path, err)
+	}
+
+	creds := wrapped.staticCredentials
+	if wrapped.Credentials != nil {
+		creds = *wrapped.Credentials
+	}
+	if err := creds.validate(); err != nil {
+		return staticCredentials{}, fmt.Errorf("%s: %s", path, err)
+	}
+	return creds, nil
+}
+
+// checkWebIdentityEnv makes sure the token file used by IRSA on EKS, or any
+// other web identity setup, can be read. Without this a missing or
+// unreadable token only shows up as a vague error from the credential chain.
+func checkWebIdentityEnv() error {
+	roleARN := os.Getenv("AWS_ROLE_ARN")
+	tokenFile := os.Getenv("AWS_WEB_IDENTITY_TOKEN_FILE")
+	switch {
+	case roleARN == "" && tokenFile == "":
+		return nil
+	case roleARN == "":
+		return fmt.Errorf("AWS_WEB_IDENTITY_TOKEN_FILE is set but AWS_ROLE_ARN is not, both are needed for web identity credentials")
+	case tokenFile == "":
+		return fmt.Errorf("AWS_ROLE_ARN is set but AWS_WEB_IDENTITY_TOKEN_FILE is not, both are needed for web identity credentials")
+	}
+
+	token, err := os.ReadFile(tokenFile)
+	if err != nil {
+		return fmt.Errorf("the web identity token file %s can not be read, check the service account token is mounted: %s", tokenFile, err)
+	}
+	if len(token) == 0 {
+		return fmt.Errorf("the web identity token file %s is empty", tokenFile)
+	}
+	return nil
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"encoding/csv"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+)
+
+// csvHeader names the columns of a csv listing. Object versions and delete
+// markers share the columns and are told apart by Type, as in parquet.
+var csvHeader = []string{"Key", "VersionId", "IsLatest", "Size", "LastModified", "StorageClass", "Type"}
+
+// toCSV writes a row for each object version and delete marker. An unknown
+// LastModified, from an older manifest, is left empty.
+func (objList *objectList) toCSV() string {
+	buf := &strings.Builder{}
+	w := csv.NewWriter(buf)
+	w.Write(csvHeader)
+	for _, obj := range objList.Objects {
+		w.Write([]string{obj.Key, obj.VersionId, strconv.FormatBool(obj.IsLatest), strconv.FormatInt(obj.Size, 10), csvTime(obj.LastModified), obj.StorageClass, parquetTypeVersion})
+	}
+	for _, dm := range objList.DeleteMarkers {
+		w.Write([]string{aws.StringValue(dm.Key), aws.StringValue(dm.VersionId), strconv.FormatBool(aws.BoolValue(dm.IsLatest)), "0", csvTime(dm.LastModified), "", parquetTypeDeleteMarker})
+	}
+	w.Flush()
+	// The callers add the final newline, as they do for JSON.
+	return strings.TrimSuffix(buf.String(), "\n")
+}
+
+func csvTime(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339)
+}
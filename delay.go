@@ -0,0 +1,42 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"time"
+)
+
+// countdown prints message and waits for delay, ticking down once a second.
+// It returns false if the user pressed Ctrl-C while waiting, in which case
+// nothing should be deleted.
+func countdown(w io.Writer, message string, delay time.Duration) bool {
+	if delay <= 0 {
+		return true
+	}
+
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt)
+	defer signal.Stop(interrupt)
+
+	fmt.Fprintf(w, "%s in %s... (Ctrl-C to abort)\n", message, delay.Round(time.Second))
+	deadline := time.After(delay)
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	remaining := delay
+	for {
+		select {
+		case <-interrupt:
+			fmt.Fprintln(w, "Aborted, nothing was deleted.")
+			return false
+		case <-deadline:
+			return true
+		case <-ticker.C:
+			remaining -= time.Second
+			if remaining > 0 {
+				fmt.Fprintf(w, "%s...\n", remaining.Round(time.Second))
+			}
+		}
+	}
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"net/url"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+// newListingClient returns an S3 client for listing that asks for keys to be
+// URL encoded and decodes them again as each response is read.
+//
+// Without encoding, keys holding characters that are not valid in XML can not
+// be listed correctly. The decoding is done in a handler, before the SDK
+// reads the markers for the next page, so paging and everything after it only
+// ever sees the real keys. A key taken from a listing can be used as it is in
+// DeleteObjects.
+func newListingClient(awsSession *session.Session) *s3.S3 {
+	s3Handler := s3.New(awsSession)
+	s3Handler.Handlers.Build.PushFront(requestURLEncoding)
+	s3Handler.Handlers.Unmarshal.PushBack(decodeURLEncoding)
+	return s3Handler
+}
+
+func requestURLEncoding(r *request.Request) {
+	switch input := r.Params.(type) {
+	case *s3.ListObjectVersionsInput:
+		input.EncodingType = aws.String(s3.EncodingTypeUrl)
+	case *s3.ListMultipartUploadsInput:
+		input.EncodingType = aws.String(s3.EncodingTypeUrl)
+	}
+}
+
+func decodeURLEncoding(r *request.Request) {
+	if r.Error != nil {
+		return
+	}
+
+	values := []*string{}
+	switch out := r.Data.(type) {
+	case *s3.ListObjectVersionsOutput:
+		if aws.StringValue(out.EncodingType) != s3.EncodingTypeUrl {
+			return
+		}
+		values = append(values, out.Delimiter, out.KeyMarker, out.NextKeyMarker, out.Prefix)
+		for _, v := range out.Versions {
+			values = append(values, v.Key)
+		}
+		for _, dm := range out.DeleteMarkers {
+			values = append(values, dm.Key)
+		}
+		for _, p := range out.CommonPrefixes {
+			values = append(values, p.Prefix)
+		}
+	case *s3.ListMultipartUploadsOutput:
+		if aws.StringValue(out.EncodingType) != s3.EncodingTypeUrl {
+			return
+		}
+		values = append(values, out.Delimiter, out.KeyMarker, out.NextKeyMarker, out.Prefix)
+		for _, u := range out.Uploads {
+			values = append(values, u.Key)
+		}
+		for _, p := range out.CommonPrefixes {
+			values = append(values, p.Prefix)
+		}
+	}
+
+	for _, v := range values {
+		if v == nil {
+			continue
+		}
+		// S3 encodes spaces as "+", so QueryUnescape rather than
+		// PathUnescape.
+		decoded, err := url.QueryUnescape(*v)
+		if err != nil {
+			r.Error = awserr.New(request.ErrCodeSerialization, "failed to decode a URL encoded key", err)
+			return
+		}
+		*v = decoded
+	}
+}
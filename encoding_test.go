@@ -0,0 +1,68 @@
+package main
+
+import (
+	"net/url"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+func TestDecodeURLEncodingRoundTrip(t *testing.T) {
+	keys := []string{
+		"plain/key.txt",
+		"with spaces/and  two.txt",
+		"unicode/日本語/ñandú-😀.txt",
+		"special/a+b&c=d?e#f%g'h\"i<j>k.txt",
+		"control/\x01\x1f.txt",
+		"trailing/",
+	}
+
+	out := &s3.ListObjectVersionsOutput{
+		EncodingType:  aws.String(s3.EncodingTypeUrl),
+		NextKeyMarker: aws.String(url.QueryEscape(keys[2])),
+	}
+	for _, key := range keys {
+		// S3 encodes the same way as QueryEscape, with spaces as "+".
+		out.Versions = append(out.Versions, &s3.ObjectVersion{Key: aws.String(url.QueryEscape(key))})
+		out.DeleteMarkers = append(out.DeleteMarkers, &s3.DeleteMarkerEntry{Key: aws.String(url.QueryEscape(key))})
+	}
+
+	r := &request.Request{Data: out}
+	decodeURLEncoding(r)
+	if r.Error != nil {
+		t.Fatal(r.Error)
+	}
+	for i, key := range keys {
+		if got := aws.StringValue(out.Versions[i].Key); got != key {
+			t.Errorf("version key is %q, want %q", got, key)
+		}
+		if got := aws.StringValue(out.DeleteMarkers[i].Key); got != key {
+			t.Errorf("delete marker key is %q, want %q", got, key)
+		}
+	}
+	if got := aws.StringValue(out.NextKeyMarker); got != keys[2] {
+		t.Errorf("NextKeyMarker is %q, want %q", got, keys[2])
+	}
+}
+
+func TestDecodeURLEncodingOnlyWhenEncoded(t *testing.T) {
+	out := &s3.ListObjectVersionsOutput{Versions: []*s3.ObjectVersion{{Key: aws.String("a+b%20c")}}}
+	decodeURLEncoding(&request.Request{Data: out})
+	if got := aws.StringValue(out.Versions[0].Key); got != "a+b%20c" {
+		t.Errorf("key is %q, a response that was not encoded must be left alone", got)
+	}
+}
+
+func TestDecodeURLEncodingInvalid(t *testing.T) {
+	out := &s3.ListObjectVersionsOutput{
+		EncodingType: aws.String(s3.EncodingTypeUrl),
+		Versions:     []*s3.ObjectVersion{{Key: aws.String("bad%zz")}},
+	}
+	r := &request.Request{Data: out}
+	decodeURLEncoding(r)
+	if r.Error == nil {
+		t.Error("expected an error for an invalid escape")
+	}
+}
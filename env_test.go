@@ -0,0 +1,61 @@
+package main
+
+import (
+	"flag"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestEnvName(t *testing.T) {
+	if got := envName("max-delete-requests"); got != "EMPTY_S3_MAX_DELETE_REQUESTS" {
+		t.Fatalf("got %s", got)
+	}
+}
+
+func TestApplyEnvironment(t *testing.T) {
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	profile := fs.String("profile", "", "")
+	batchSize := fs.Int("batch-size", 1000, "")
+	dryRun := fs.Bool("dry-run", false, "")
+	region := fs.String("region", "us-east-1", "")
+
+	t.Setenv("EMPTY_S3_PROFILE", "from-env")
+	t.Setenv("EMPTY_S3_BATCH_SIZE", "50")
+	t.Setenv("EMPTY_S3_DRY_RUN", "true")
+	if err := fs.Parse([]string{"-batch-size", "10"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := applyEnvironment(fs); err != nil {
+		t.Fatalf("failed: %s", err)
+	}
+
+	if *profile != "from-env" {
+		t.Errorf("profile is %q, want the environment", *profile)
+	}
+	if *batchSize != 10 {
+		t.Errorf("batch-size is %d, want the command line to win", *batchSize)
+	}
+	if !*dryRun {
+		t.Errorf("dry-run is not set from the environment")
+	}
+	if *region != "us-east-1" {
+		t.Errorf("region is %q, want the default", *region)
+	}
+}
+
+func TestApplyEnvironmentInvalid(t *testing.T) {
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	fs.Int("batch-size", 1000, "")
+
+	t.Setenv("EMPTY_S3_BATCH_SIZE", "lots")
+	if err := fs.Parse(nil); err != nil {
+		t.Fatal(err)
+	}
+	err := applyEnvironment(fs)
+	if err == nil || !strings.Contains(err.Error(), "EMPTY_S3_BATCH_SIZE") {
+		t.Fatalf("got %v, want an error naming EMPTY_S3_BATCH_SIZE", err)
+	}
+}
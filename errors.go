@@ -0,0 +1,95 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrEmptyBucket is returned by listObjects when the bucket is empty.
+var ErrEmptyBucket = errors.New("no objects found")
+
+// ErrNoMatchingObjects is returned by listObjects when the bucket has objects
+// but none of them matched the prefixes or filters.
+var ErrNoMatchingObjects = errors.New("no objects matched the prefixes or filters")
+
+// AuthError is returned when the credentials could not be resolved or were
+// rejected.
+type AuthError struct {
+	Err error
+}
+
+func (e *AuthError) Error() string {
+	return fmt.Sprintf("credentials error: %s", e.Err)
+}
+
+func (e *AuthError) Unwrap() error {
+	return e.Err
+}
+
+// ListError is returned when listing the bucket failed.
+type ListError struct {
+	Bucket string
+	Err    error
+}
+
+func (e *ListError) Error() string {
+	return fmt.Sprintf("listing bucket '%s' failed: %s", e.Bucket, e.Err)
+}
+
+func (e *ListError) Unwrap() error {
+	return e.Err
+}
+
+// DeleteError is returned when some or all of the objects were not deleted.
+// Err is set if a request failed outright, Failed holds the objects S3
+// reported it could not delete, up to -max-error-details of them.
+// FailureCount is the full number.
+type DeleteError struct {
+	Bucket       string
+	Failed       []string
+	FailureCount int64
+	Err          error
+}
+
+func (e *DeleteError) Error() string {
+	if e.Err != nil {
+		return fmt.Sprintf("deleting from bucket '%s' failed: %s", e.Bucket, e.Err)
+	}
+	return fmt.Sprintf("%d objects in bucket '%s' could not be deleted", e.FailureCount, e.Bucket)
+}
+
+func (e *DeleteError) Unwrap() error {
+	return e.Err
+}
+
+// Exit codes used by the CLI. Usage errors exit with 2 from the flag package.
+const (
+	exitError        = 1
+	exitBucketEmpty  = 1
+	exitNoMatches    = 3
+	exitAuthFailed   = 4
+	exitListFailed   = 5
+	exitDeleteFailed = 6
+)
+
+// exitCodeFor maps an error to the exit code the CLI uses for it.
+func exitCodeFor(err error) int {
+	var authErr *AuthError
+	var listErr *ListError
+	var deleteErr *DeleteError
+	switch {
+	case err == nil:
+		return 0
+	case errors.Is(err, ErrEmptyBucket):
+		return exitBucketEmpty
+	case errors.Is(err, ErrNoMatchingObjects):
+		return exitNoMatches
+	case errors.As(err, &authErr):
+		return exitAuthFailed
+	case errors.As(err, &listErr):
+		return exitListFailed
+	case errors.As(err, &deleteErr):
+		return exitDeleteFailed
+	}
+	return exitError
+}
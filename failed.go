@@ -0,0 +1,58 @@
+package main
+
+import (
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+)
+
+// failedCollector gathers every object that could not be deleted into a
+// listing that -from-manifest can read, so just those can be tried again. It
+// keeps all of them, unlike deleteResult which stops at -max-error-details.
+//
+// S3 does not say if a failed entry was a delete marker, so everything is
+// written as an object. Deleting by key and version id works the same for
+// both.
+type failedCollector struct {
+	NopObserver
+	list *objectList
+}
+
+func newFailedCollector(bucket string) *failedCollector {
+	list := newObjectList()
+	list.Bucket = bucket
+	return &failedCollector{list: list}
+}
+
+func (c *failedCollector) OnBatchDeleted(event BatchEvent) {
+	for _, e := range event.Errors {
+		c.add(aws.StringValue(e.Key), aws.StringValue(e.VersionId))
+	}
+}
+
+// OnBatchError adds the whole batch, as none of it is known to be deleted.
+func (c *failedCollector) OnBatchError(event BatchEvent, err error) {
+	for _, id := range event.Objects {
+		c.add(aws.StringValue(id.Key), aws.StringValue(id.VersionId))
+	}
+}
+
+func (c *failedCollector) add(key, versionId string) {
+	if versionId == "" {
+		// Objects written before versioning was turned on.
+		versionId = "null"
+	}
+	c.list.add(object{Key: key, VersionId: versionId})
+}
+
+// write saves the failed objects to path as JSON, gzipped if path ends in .gz.
+// Nothing is written if there were no failures.
+func (c *failedCollector) write(path string) (bool, error) {
+	if c.list.ObjectCount == 0 {
+		return false, nil
+	}
+	now := time.Now().UTC()
+	c.list.GeneratedAt = &now
+	return true, writeListFile(path, "pretty-json", c.list, outputOptions{compress: strings.HasSuffix(path, ".gz")})
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+// keyFilter reports if a key should be included in the listing.
+type keyFilter func(key string) bool
+
+func matchesAll(filters []keyFilter, key string) bool {
+	for _, f := range filters {
+		if !f(key) {
+			return false
+		}
+	}
+	return true
+}
+
+// newGlobFilter matches keys against a glob, see globToRegexp. A bad pattern
+// fails at startup.
+func newGlobFilter(pattern string) (keyFilter, error) {
+	re, err := globToRegexp(pattern)
+	if err != nil {
+		return nil, err
+	}
+	return func(key string) bool {
+		return re.MatchString(key)
+	}, nil
+}
+
+// newRegexFilter matches keys containing a match for the regular expression,
+// or with exclude, keys that do not. Anchor it with ^ and $ to match whole
+// keys.
+func newRegexFilter(pattern string, exclude bool) (keyFilter, error) {
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+	return func(key string) bool {
+		return re.MatchString(key) != exclude
+	}, nil
+}
+
+// versionFilter reports if an object version should be included in the
+// listing. Unlike a keyFilter it can look at the details of the version.
+// Delete markers are never passed to one.
+type versionFilter func(obj object) bool
+
+func versionMatchesAll(filters []versionFilter, obj object) bool {
+	for _, f := range filters {
+		if !f(obj) {
+			return false
+		}
+	}
+	return true
+}
+
+// noVersions leaves every object version out of the listing, so only the
+// delete markers are left.
+func noVersions(obj object) bool {
+	return false
+}
+
+// deleteMarkerMatchesAll applies version filters to a delete marker.
+func deleteMarkerMatchesAll(filters []versionFilter, dm *s3.DeleteMarkerEntry) bool {
+	if len(filters) == 0 {
+		return true
+	}
+	obj := newObject(aws.StringValue(dm.Key), aws.StringValue(dm.VersionId), 0)
+	obj.LastModified = dm.LastModified
+	obj.IsLatest = aws.BoolValue(dm.IsLatest)
+	return versionMatchesAll(filters, obj)
+}
+
+// newLatestFilter matches versions that are, or with latest false are not,
+// the current version of their key.
+func newLatestFilter(latest bool) versionFilter {
+	return func(obj object) bool {
+		return obj.IsLatest == latest
+	}
+}
+
+// newStorageClassFilter matches versions in any of the storage classes. The
+// names are checked against the ones S3 knows about, in any case.
+func newStorageClassFilter(classes []string) (versionFilter, error) {
+	known := s3.StorageClass_Values()
+	upper := make([]string, 0, len(classes))
+	for _, class := range classes {
+		class = strings.ToUpper(class)
+		if !contains(known, class) {
+			return nil, fmt.Errorf("%s is not a storage class, use one of %s", class, strings.Join(known, ","))
+		}
+		upper = append(upper, class)
+	}
+	return func(obj object) bool {
+		class := obj.StorageClass
+		if class == "" {
+			// Some S3 compatible stores leave it out for standard storage.
+			class = s3.StorageClassStandard
+		}
+		return contains(upper, class)
+	}, nil
+}
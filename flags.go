@@ -0,0 +1,433 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// runFlags holds the command line flags of a run.
+type runFlags struct {
+	bucketName            *string
+	bucketConcurrency     *int
+	maxDeleteRequests     *int
+	bucketAllowPattern    *string
+	profile               *string
+	accessKeyId           *string
+	secretAccessKey       *string
+	sessionToken          *string
+	credentialsFile       *string
+	endpointURL           *string
+	forcePathStyle        *bool
+	credentialSource      *string
+	awsRegion             *string
+	regionPerBucket       *string
+	regionPerBucketFile   *string
+	noRegionAutodetect    *bool
+	ignoreLifecycle       *bool
+	format                *string
+	prefix                *string
+	prefixFile            *string
+	prefixConcurrency     *int
+	discoverPrefixes      *bool
+	listShards            *int
+	glob                  *string
+	include               patternList
+	exclude               patternList
+	includeRegex          *string
+	excludeRegex          *string
+	olderThan             *string
+	newerThan             *string
+	minSize               *string
+	maxSize               *string
+	tag                   *string
+	tagConcurrency        *int
+	storageClass          *string
+	output                *string
+	outputChunkSize       *int
+	outputCompress        *bool
+	fromManifest          *string
+	manifestMaxAge        *time.Duration
+	listOnly              *bool
+	resumeFile            *string
+	deleteWhileListing    *bool
+	maxInFlight           *int64
+	template              *string
+	sort                  *bool
+	dryRun                *bool
+	showObjects           *bool
+	reviewThenDelete      *bool
+	yes                   *bool
+	selectObjects         *bool
+	checkLocks            *bool
+	checkLocksRate        *int
+	checksumVerify        *bool
+	sseCustomerKey        *string
+	sseCustomerKeyMD5     *string
+	showSummaryOnly       *bool
+	dryRunSummary         *bool
+	breakdown             *bool
+	keepVersions          *int
+	deleteMarkersOnly     *bool
+	noncurrentOnly        *bool
+	currentOnly           *bool
+	skipDeleteMarkers     *bool
+	abortMultipartUploads *bool
+	noFailIfEmpty         *bool
+	maxObjects            *int64
+	force                 *bool
+	verbose               verbosityFlag
+	delay                 *time.Duration
+	validateDelete        *int
+	simulate              *bool
+	simulateLatency       *time.Duration
+	batchSize             *int
+	tagForDeletion        *bool
+	deletionTag           *string
+	logFormat             *string
+	logLevel              *string
+	logFile               *string
+	logFileMaxSize        *string
+	logFileBackups        *int
+	progress              *bool
+	quiet                 *bool
+	singleDelete          *bool
+	archiveBucket         *string
+	largestFirst          *bool
+	maxRetries            *int
+	retryBaseDelay        *time.Duration
+	retryMaxDelay         *time.Duration
+	maxRequestsPerSecond  *int
+	noAdaptiveBackoff     *bool
+	quietDelete           *bool
+	concurrency           *int
+	maxBatchBytes         *int
+	maxErrorDetails       *int
+	streamResults         *bool
+	failedOutput          *string
+	stats                 *bool
+	clearConfig           *bool
+	emitMetrics           *bool
+	metricsNamespace      *string
+	notifyTopic           *string
+	correlationID         *string
+	report                *string
+	jsonSchema            *bool
+	version               *bool
+}
+
+// defineFlags defines every flag on fs. The values are only there once fs
+// has been parsed.
+func defineFlags(fs *flag.FlagSet) *runFlags {
+	f := &runFlags{}
+	f.bucketName = fs.String("bucket-name", "", "Name of the bucket to empty, or an s3://bucket/prefix URI to only empty that prefix. Several buckets can be given separated by commas, they are emptied one after the other. The buckets can also be given as arguments after the flags.")
+	f.bucketConcurrency = fs.Int("bucket-concurrency", 1, "How many of the buckets given to -bucket-name are emptied at the same time. Each gets its own session and listing concurrency.")
+	f.maxDeleteRequests = fs.Int("max-delete-requests", 10, "The most delete requests in flight at once, across all the buckets being emptied.")
+	f.bucketAllowPattern = fs.String("bucket-allow-pattern", "", "A regular expression every bucket name must match in full, otherwise nothing is done at all. Use it to make sure only buckets like ci-test-.* can ever be emptied.")
+	f.profile = fs.String("profile", "", "AWS Profile to use, if there is one.")
+	f.accessKeyId = fs.String("access-key-id", "", "AWS access key id to use instead of the default credential chain. Requires -secret-access-key.")
+	f.secretAccessKey = fs.String("secret-access-key", "", "AWS secret access key. Passing secrets on the command line is insecure, prefer -credentials-file or environment variables.")
+	f.sessionToken = fs.String("session-token", "", "AWS session token to use with -access-key-id and -secret-access-key.")
+	f.credentialsFile = fs.String("credentials-file", "", "Path to a JSON file containing AccessKeyId, SecretAccessKey and optionally SessionToken. The output of 'aws sts assume-role' is accepted.")
+	f.endpointURL = fs.String("endpoint-url", "", "Send requests to this endpoint instead of AWS, for S3 compatible stores.")
+	f.forcePathStyle = fs.Bool("force-path-style", false, "Use path style URLs, endpoint/bucket/key, instead of bucket.endpoint/key. Needed by LocalStack, MinIO and most S3 compatible stores.")
+	f.credentialSource = fs.String("credential-source", "default", fmt.Sprintf("Where to get AWS credentials from, one of %s. 'default' uses the normal AWS credential chain.", strings.Join(credentialSources, ",")))
+	f.awsRegion = fs.String("aws-region", "", "The region for the aws connection. If set it will override what is set in AWS_REGION. If there is no AWS_REGION, then eu-west-1 will be used.")
+	f.regionPerBucket = fs.String("region-per-bucket", "", "Comma separated bucket=region pairs. A bucket listed here uses that region instead of the detected one.")
+	f.regionPerBucketFile = fs.String("region-per-bucket-file", "", "Path to a JSON object of bucket names to regions, used like -region-per-bucket. Entries in -region-per-bucket win.")
+	f.noRegionAutodetect = fs.Bool("no-region-autodetect", false, "Do not look up the region of the bucket. Useful for S3 compatible stores that do not support it.")
+	f.ignoreLifecycle = fs.Bool("ignore-lifecycle", false, "Do not warn when the bucket has lifecycle rules that expire objects.")
+	f.format = fs.String("format", "pretty-json", fmt.Sprintf("If -dry-run or -show-objects is used, the format of the output, %s are available. parquet can only be used with -output. count only prints the totals, and is much lighter on memory for very large buckets.", strings.Join(VALID_FORMATS, ",")))
+	f.prefix = fs.String("prefix", "", "Only delete keys that start with this prefix, eg logs/2021/. Their versions and delete markers are deleted too.")
+	f.prefixFile = fs.String("prefix-file", "", "Only delete keys under the prefixes listed in this file, one per line. Blank lines and lines starting with # are ignored.")
+	f.prefixConcurrency = fs.Int("prefix-concurrency", 4, "How many prefixes from -prefix-file or -discover-prefixes are listed at the same time.")
+	f.discoverPrefixes = fs.Bool("discover-prefixes", false, "Find the prefixes one level down with a delimiter listing first, then list them at the same time, -prefix-concurrency at a time. Used with -prefix it looks one level below it.")
+	f.listShards = fs.Int("list-shards", 1, fmt.Sprintf("Split the listing by the first character of the keys into this many ranges listed in parallel, up to %d. Can not be used with -prefix or -prefix-file.", len(shardAlphabet)))
+	f.glob = fs.String("glob", "", "Only include keys matching this shell style glob, eg 'cache/*/tmp-*'. A '*' does not match '/', a '**' does. It is written like the -include globs.")
+	fs.Var(&f.include, "include", "Only include keys matching this glob, eg '*.tmp' or 'backups/**/old-*'. '*' does not match '/' but '**' does. Can be given more than once, a key only has to match one.")
+	fs.Var(&f.exclude, "exclude", "Leave out keys matching this glob, in the same form as -include. Can be given more than once.")
+	f.includeRegex = fs.String("include-regex", "", "Only include keys with a match for this regular expression, eg '\\.log$'. Use ^ and $ to match the whole key.")
+	f.excludeRegex = fs.String("exclude-regex", "", "Leave out keys with a match for this regular expression, eg '^config/'. Applied after -include-regex.")
+	f.olderThan = fs.String("older-than", "", "Only include versions and delete markers last modified before this. Either an age like 90d or 36h, or a date like 2023-01-01 or 2023-01-01T12:00:00Z.")
+	f.newerThan = fs.String("newer-than", "", "Only include versions and delete markers last modified after this, in the same form as -older-than.")
+	f.minSize = fs.String("min-size", "", "Only include versions of at least this size, eg 10MB or 1.5GiB. KB, MB, GB and TB are powers of 1000, KiB, MiB, GiB and TiB powers of 1024.")
+	f.maxSize = fs.String("max-size", "", "Only include versions of at most this size, in the same form as -min-size.")
+	f.tag = fs.String("tag", "", "Only include versions with this key=value tag, eg ttl=short. This looks up the tags of every version listed, one request each. Delete markers have no tags and are kept.")
+	f.tagConcurrency = fs.Int("tag-concurrency", 10, "How many tag lookups -tag makes at the same time.")
+	f.storageClass = fs.String("storage-class", "", "Only include versions in these storage classes, separated by commas, eg STANDARD_IA,GLACIER. Delete markers have no storage class and are kept.")
+	f.output = fs.String("output", "", "Write the object listing to this file instead of stdout.")
+	f.outputChunkSize = fs.Int("output-chunk-size", 0, "Used with -output, split the listing into numbered files of at most this many entries, eg manifest-0001.json. 0 means a single file.")
+	f.outputCompress = fs.Bool("output-compress", false, "Used with -output, gzip the listing. .gz is added to the file names.")
+	f.fromManifest = fs.String("from-manifest", "", "Delete exactly the objects in this listing, made earlier with -output in a JSON format, instead of listing the bucket.")
+	f.manifestMaxAge = fs.Duration("manifest-max-age", 24*time.Hour, "Warn if the -from-manifest listing is older than this.")
+	f.listOnly = fs.Bool("list-only", false, "Only list the bucket and write the listing to -output, or stdout. Nothing is ever deleted in this mode.")
+	f.resumeFile = fs.String("resume-file", "", "List and delete a page at a time, saving progress to this file after each page. If the file exists the run carries on from where it got to. The file is removed once the bucket is done.")
+	f.deleteWhileListing = fs.Bool("delete-while-listing", false, "Delete each page of the listing as soon as it is listed, instead of listing everything first. Memory use stays flat however big the bucket is. -resume-file always does this.")
+	f.maxInFlight = fs.Int64("max-in-flight", 10000, "Used with -delete-while-listing or -resume-file, listing runs ahead of deleting until this many listed objects are waiting to be deleted, then pauses until the deletes catch up. 0 means no limit.")
+	f.template = fs.String("template", "", "The Go template used by -format template. It is run for each version and delete marker, eg '{{.Key}} {{.VersionId}}', with the fields Key, VersionId, Size, LastModified, StorageClass, IsLatest and Type.")
+	f.sort = fs.Bool("sort", false, "Sort the listing by key and then version id before it is shown or written, so listings of the same objects are identical and can be diffed.")
+	f.dryRun = fs.Bool("dry-run", false, "Show versions to be deleted.")
+	f.showObjects = fs.Bool("show-objects", false, "Show the objects before attempting to delete them.")
+	f.reviewThenDelete = fs.Bool("review-then-delete", false, "After listing, show a summary of what will be deleted and ask for confirmation, then delete exactly what was listed without listing again.")
+	f.yes = fs.Bool("yes", false, "Used with -review-then-delete, show the summary but do not wait for confirmation.")
+	f.selectObjects = fs.Bool("select", false, "After listing, interactively choose which objects to delete. Requires a terminal.")
+	f.checkLocks = fs.Bool("check-locks", false, "Used with -dry-run, look up the Object Lock retention and legal hold of every object. This costs one extra API call per object.")
+	f.checkLocksRate = fs.Int("check-locks-rate", 20, "The maximum number of lock lookups per second made by -check-locks.")
+	f.checksumVerify = fs.Bool("checksum-verify", false, "Used with -dry-run, add the ETag and owner of each version to the listing and warn about any that are missing or look wrong. No extra API calls are made.")
+	f.sseCustomerKey = fs.String("sse-customer-key", "", "The 32 byte SSE-C key, used when looking up metadata of SSE-C encrypted objects. Deleting does not need it.")
+	f.sseCustomerKeyMD5 = fs.String("sse-customer-key-md5", "", "The base64 MD5 of -sse-customer-key. Worked out for you if not given.")
+	f.showSummaryOnly = fs.Bool("show-summary-only", false, "Show only the counts of objects, versions, delete markers and the total size before deleting, rather than every object.")
+	f.dryRunSummary = fs.Bool("dry-run-summary", false, "A dry run that only prints the number and total size of the versions and delete markers under each top-level prefix. Nothing is kept from the listing, so it works on buckets of any size.")
+	f.breakdown = fs.Bool("breakdown", false, "Used with -dry-run, print a table of object counts and sizes grouped by top-level prefix.")
+	f.keepVersions = fs.Int("keep-versions", 0, "Keep the newest N versions of each key. Older versions and delete markers that are not the current version of their key are deleted.")
+	f.deleteMarkersOnly = fs.Bool("delete-markers-only", false, "Only delete delete markers, which brings back the objects they hide. Object versions are not touched.")
+	f.noncurrentOnly = fs.Bool("noncurrent-only", false, "Only delete noncurrent versions and delete markers. The current version of every key is kept, so nothing disappears from the bucket.")
+	f.currentOnly = fs.Bool("current-only", false, "Only delete the current version of each key. S3 puts a delete marker in its place, so the older versions are kept but the key no longer shows up.")
+	f.skipDeleteMarkers = fs.Bool("skip-delete-markers", false, "Keep delete markers, only object versions are deleted.")
+	f.abortMultipartUploads = fs.Bool("abort-multipart-uploads", false, "Also abort incomplete multipart uploads, these are not removed by deleting objects. With -dry-run they are shown in the listing.")
+	f.noFailIfEmpty = fs.Bool("no-fail-if-empty", false, "Exit successfully if there is nothing to delete. By default an empty bucket exits with 1 and a listing where nothing matched the prefixes or filters exits with 3.")
+	f.maxObjects = fs.Int64("max-objects", 0, "Refuse to delete anything if more than this many objects are found. 0 means no limit.")
+	f.force = fs.Bool("force", false, "Delete even if the number of objects found is above -max-objects.")
+	fs.Var(&f.verbose, "verbose", fmt.Sprintf("Print extra detail about what is happening. -verbose on its own is level 1, which shows things like the AWS identity in use and a line for each delete batch with its request id. -verbose=%d adds SDK logging of retries and request errors, -verbose=%d logs every request and response in full.", verboseRequests, verboseWire))
+	f.delay = fs.Duration("delay", 0, "Wait this long before deleting, with a countdown, so there is time to press Ctrl-C. Not used by -dry-run or -simulate.")
+	f.validateDelete = fs.Int("validate-delete", 0, "Really delete at most this many of the objects found, report how it went and stop. Use it to check the delete permissions before a long run. 0 turns it off.")
+	f.simulate = fs.Bool("simulate", false, "Run the full delete process, but do not send the delete requests to S3. Useful to test batching and performance safely.")
+	f.simulateLatency = fs.Duration("simulate-latency", 100*time.Millisecond, "How long each simulated delete request takes with -simulate.")
+	f.batchSize = fs.Int("batch-size", maxAWSBatchSize, fmt.Sprintf("The number of objects deleted per request. Must be between 1 and %d, the limit can only be raised with -endpoint-url.", maxAWSBatchSize))
+	f.tagForDeletion = fs.Bool("tag-for-deletion", false, "Tag every object version with -deletion-tag instead of deleting it, so a lifecycle rule can expire them. Delete markers are left alone.")
+	f.deletionTag = fs.String("deletion-tag", "pending-delete=true", "The key=value tag added by -tag-for-deletion.")
+	f.logFormat = fs.String("log-format", "", fmt.Sprintf("Also log every bucket, listing page and delete batch to stderr, as one of %s. json writes one object per line for log aggregators.", strings.Join(logFormats, ",")))
+	f.logLevel = fs.String("log-level", "info", fmt.Sprintf("The lowest level logged by -log-format, one of %s. debug adds each listing page and batch sent, warn and error only log failures.", strings.Join(logLevelNames, ",")))
+	f.logFile = fs.String("log-file", "", "Log everything, every bucket, listing page, batch and failed object, to this file whatever the console shows. It is written as text, or JSON with -log-format json.")
+	f.logFileMaxSize = fs.String("log-file-max-size", "100MB", "Start a new -log-file once it reaches this size, eg 10MB. The old file is kept as <file>.1.")
+	f.logFileBackups = fs.Int("log-file-backups", 5, "How many old -log-file copies to keep.")
+	f.progress = fs.Bool("progress", false, "Show how many objects have been listed and deleted, the delete rate and an ETA on stderr. On a terminal the line updates every second, otherwise a new line is written every 30 seconds.")
+	f.quiet = fs.Bool("quiet", false, "Only print errors, for scripts where only failures matter. Output that was asked for, like a dry run listing or -stats, is still printed.")
+	f.singleDelete = fs.Bool("single-delete", false, "Delete each object with its own DeleteObject request, for S3 compatible stores without DeleteObjects. This is used automatically if DeleteObjects is not implemented, and is much slower.")
+	f.archiveBucket = fs.String("archive-bucket", "", "Copy each object version into this bucket, under the same key, before deleting it. Versions that fail to copy are not deleted. Objects over 5 GiB can not be copied.")
+	f.largestFirst = fs.Bool("largest-first", false, "Delete the largest versions first so the most storage is freed early. Directory markers are then deleted in size order rather than after everything else.")
+	f.maxRetries = fs.Int("max-retries", defaultMaxRetries, "How many times a failed request is retried before giving up.")
+	f.retryBaseDelay = fs.Duration("retry-base-delay", 0, "The wait before the first retry, doubled for each retry after it. 0 keeps the SDK default of 30ms, or 500ms after a throttle.")
+	f.retryMaxDelay = fs.Duration("retry-max-delay", 0, "The longest wait before a retry. 0 keeps the SDK default of 5 minutes.")
+	f.maxRequestsPerSecond = fs.Int("max-requests-per-second", 0, "Send at most this many requests a second, listing and deleting together, across all buckets. Use it to leave room for other workloads on the same buckets. 0 means no limit.")
+	f.noAdaptiveBackoff = fs.Bool("no-adaptive-backoff", false, "Do not slow every request down when S3 returns SlowDown or 503 errors. The SDK still retries the throttled requests themselves.")
+	f.quietDelete = fs.Bool("quiet-delete", true, "Ask S3 to only send back the failures of each delete batch rather than every key deleted, which makes the responses much smaller. Turn it off with -quiet-delete=false for stores that do not support it.")
+	f.concurrency = fs.Int("concurrency", 1, "How many delete batches are sent at the same time for each bucket. -max-delete-requests still caps the total across all buckets.")
+	f.maxBatchBytes = fs.Int("max-batch-bytes", maxDeleteRequestBytes, "Start a new delete batch early if the keys in it would make the request larger than this many bytes. 0 only limits batches by -batch-size.")
+	f.maxErrorDetails = fs.Int("max-error-details", 1000, "Keep the details of at most this many failed deletes, the rest are only counted. 0 keeps them all.")
+	f.streamResults = fs.Bool("stream-results", false, "Write a JSON record to stdout as each delete batch finishes, one per line. Everything else is written to stderr.")
+	f.failedOutput = fs.String("failed-output", "", "Write the objects that could not be deleted to this file, in the same JSON format as -output, so they can be tried again with -from-manifest. Gzipped if the name ends in .gz. Only written if something failed.")
+	f.stats = fs.Bool("stats", false, "Print the latency of the delete batches and the overall throughput once deleting has finished.")
+	f.clearConfig = fs.Bool("clear-config", false, "Once the bucket has been emptied, also remove its bucket policy, lifecycle, CORS and website configuration. Each is removed on its own and failures are reported. Not done by -dry-run or -simulate.")
+	f.emitMetrics = fs.Bool("emit-metrics", false, "Publish CloudWatch metrics about the deletion when the run finishes. Failing to publish does not fail the run.")
+	f.metricsNamespace = fs.String("metrics-namespace", "EmptyS3Bucket", "The CloudWatch namespace used by -emit-metrics.")
+	f.notifyTopic = fs.String("notify-sns-topic-arn", "", "Publish a JSON message with the outcome of each bucket to this SNS topic when it finishes, whether it worked or not. Failing to publish does not fail the run.")
+	f.correlationID = fs.String("correlation-id", "", "An id sent with every AWS request, in the X-Correlation-Id header and the User-Agent, and written to the report, so the run can be found in CloudTrail. A random UUID is used if not given.")
+	f.report = fs.String("report", "", "Write a JSON report of the run to this file. The report is written even if the run fails.")
+	f.jsonSchema = fs.Bool("dry-run-json-schema", false, "Print the JSON Schema of the json and pretty-json listings, then exit.")
+	f.version = fs.Bool("version", false, "Print the version.")
+	fs.BoolVar(f.version, "v", false, "Print the version. Same as -version.")
+	return f
+}
+
+// flagUse is a flag, or a flag with a particular value, and whether the run
+// uses it.
+type flagUse struct {
+	name string
+	used bool
+}
+
+// flagRule is a flag that can not be used with some flags, or can only be
+// used with others. The message is made from the same flags that are
+// checked, so the two can not disagree.
+type flagRule struct {
+	flag flagUse
+	// conflicts can not be used with flag.
+	conflicts []flagUse
+	// needs are the flags flag can only be used with, at least one of them
+	// must be used.
+	needs []flagUse
+	// why is added to the end of the message.
+	why string
+}
+
+func (r flagRule) check() error {
+	if !r.flag.used {
+		return nil
+	}
+	used := []string{}
+	for _, c := range r.conflicts {
+		if c.used {
+			used = append(used, c.name)
+		}
+	}
+	if len(used) > 0 {
+		return fmt.Errorf("%s can not be used with %s%s", r.flag.name, joinFlags(used), r.why)
+	}
+	if len(r.needs) == 0 {
+		return nil
+	}
+	needed := []string{}
+	for _, n := range r.needs {
+		if n.used {
+			return nil
+		}
+		needed = append(needed, n.name)
+	}
+	return fmt.Errorf("%s can only be used with %s%s", r.flag.name, joinFlags(needed), r.why)
+}
+
+// joinFlags lists names as "-a, -b or -c".
+func joinFlags(names []string) string {
+	if len(names) == 1 {
+		return names[0]
+	}
+	return strings.Join(names[:len(names)-1], ", ") + " or " + names[len(names)-1]
+}
+
+// flagMinimum is a number flag that can not be below min.
+type flagMinimum struct {
+	name  string
+	value int64
+	min   int64
+}
+
+func (m flagMinimum) check() error {
+	switch {
+	case m.value >= m.min:
+		return nil
+	case m.min == 0:
+		return fmt.Errorf("%s can not be negative", m.name)
+	}
+	return fmt.Errorf("%s must be at least %d", m.name, m.min)
+}
+
+// validate checks the flags that can not be used together, that need each
+// other or that can not be below a minimum. The first problem found is
+// returned. buckets are the buckets being emptied, as some flags only work
+// on a single bucket.
+func (f *runFlags) validate(buckets []string) error {
+	for _, m := range f.minimums() {
+		if err := m.check(); err != nil {
+			return err
+		}
+	}
+	for _, r := range f.rules(len(buckets)) {
+		if err := r.check(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (f *runFlags) minimums() []flagMinimum {
+	return []flagMinimum{
+		{"-output-chunk-size", int64(*f.outputChunkSize), 0},
+		{"-max-retries", int64(*f.maxRetries), 0},
+		{"-retry-base-delay", int64(*f.retryBaseDelay), 0},
+		{"-retry-max-delay", int64(*f.retryMaxDelay), 0},
+		{"-max-requests-per-second", int64(*f.maxRequestsPerSecond), 0},
+		{"-concurrency", int64(*f.concurrency), 1},
+		{"-batch-size", int64(*f.batchSize), 1},
+		{"-keep-versions", int64(*f.keepVersions), 0},
+		{"-max-in-flight", *f.maxInFlight, 0},
+		{"-validate-delete", int64(*f.validateDelete), 0},
+		{"-bucket-concurrency", int64(*f.bucketConcurrency), 1},
+		{"-max-delete-requests", int64(*f.maxDeleteRequests), 1},
+		{"-max-error-details", int64(*f.maxErrorDetails), 0},
+		{"-max-batch-bytes", int64(*f.maxBatchBytes), 0},
+		{"-check-locks-rate", int64(*f.checkLocksRate), 1},
+		{"-tag-concurrency", int64(*f.tagConcurrency), 1},
+		{"-log-file-backups", int64(*f.logFileBackups), 0},
+	}
+}
+
+func (f *runFlags) rules(bucketCount int) []flagRule {
+	dryRun := flagUse{"-dry-run", *f.dryRun}
+	simulate := flagUse{"-simulate", *f.simulate}
+	listOnly := flagUse{"-list-only", *f.listOnly}
+	selectObjects := flagUse{"-select", *f.selectObjects}
+	output := flagUse{"-output", *f.output != ""}
+	fromManifest := flagUse{"-from-manifest", *f.fromManifest != ""}
+	keepVersions := flagUse{"-keep-versions", *f.keepVersions > 0}
+	checkLocks := flagUse{"-check-locks", *f.checkLocks}
+	checksumVerify := flagUse{"-checksum-verify", *f.checksumVerify}
+	breakdown := flagUse{"-breakdown", *f.breakdown}
+	showObjects := flagUse{"-show-objects", *f.showObjects}
+	showSummaryOnly := flagUse{"-show-summary-only", *f.showSummaryOnly}
+	abortUploads := flagUse{"-abort-multipart-uploads", *f.abortMultipartUploads}
+	tag := flagUse{"-tag", *f.tag != ""}
+	formatCount := flagUse{"-format count", *f.format == "count"}
+	validateDelete := flagUse{"-validate-delete", *f.validateDelete > 0}
+	reviewThenDelete := flagUse{"-review-then-delete", *f.reviewThenDelete}
+	streamResults := flagUse{"-stream-results", *f.streamResults}
+	deleteWhileListing := flagUse{"-delete-while-listing", *f.deleteWhileListing}
+	resumeFile := flagUse{"-resume-file", *f.resumeFile != ""}
+	tagForDeletion := flagUse{"-tag-for-deletion", *f.tagForDeletion}
+	prefix := flagUse{"-prefix", *f.prefix != ""}
+	prefixFile := flagUse{"-prefix-file", *f.prefixFile != ""}
+	listShards := flagUse{"-list-shards", *f.listShards > 1}
+	deleteMarkersOnly := flagUse{"-delete-markers-only", *f.deleteMarkersOnly}
+	noncurrentOnly := flagUse{"-noncurrent-only", *f.noncurrentOnly}
+	currentOnly := flagUse{"-current-only", *f.currentOnly}
+	failedOutput := flagUse{"-failed-output", *f.failedOutput != ""}
+	minSize := flagUse{"-min-size", *f.minSize != ""}
+	storageClass := flagUse{"-storage-class", *f.storageClass != ""}
+	accessKeyId := flagUse{"-access-key-id", *f.accessKeyId != ""}
+	secretAccessKey := flagUse{"-secret-access-key", *f.secretAccessKey != ""}
+	sessionToken := flagUse{"-session-token", *f.sessionToken != ""}
+	credentialsFile := flagUse{"-credentials-file", *f.credentialsFile != ""}
+	manyBuckets := flagUse{"more than one bucket", bucketCount > 1}
+
+	// Both of these list and delete a page at a time.
+	streamingConflicts := []flagUse{dryRun, simulate, selectObjects, listOnly, fromManifest, prefixFile, listShards, keepVersions,
+		{"-largest-first", *f.largestFirst}, {"-max-objects", *f.maxObjects > 0}, showObjects, showSummaryOnly, output}
+
+	return []flagRule{
+		{flag: flagUse{"-format parquet", *f.format == "parquet"}, needs: []flagUse{output}},
+		{flag: flagUse{"-output-compress", *f.outputCompress}, conflicts: []flagUse{{"-format parquet", *f.format == "parquet"}}, why: " as parquet files are already compressed"},
+		{flag: flagUse{"-dry-run-summary", *f.dryRunSummary}, conflicts: []flagUse{listOnly, simulate, output, fromManifest, keepVersions, selectObjects, checkLocks,
+			checksumVerify, breakdown, showSummaryOnly, abortUploads, tag, formatCount, validateDelete, reviewThenDelete, streamResults, deleteWhileListing, resumeFile}},
+		{flag: formatCount, conflicts: []flagUse{output, fromManifest, keepVersions, selectObjects, checkLocks, checksumVerify, breakdown, showSummaryOnly, abortUploads},
+			needs: []flagUse{dryRun, listOnly}},
+		{flag: flagUse{"-format template", *f.format == "template"}, conflicts: []flagUse{showSummaryOnly}, needs: []flagUse{{"-template", *f.template != ""}}},
+		{flag: flagUse{"-template", *f.template != ""}, needs: []flagUse{{"-format template", *f.format == "template"}}},
+		{flag: flagUse{"-output-chunk-size", *f.outputChunkSize > 0}, needs: []flagUse{output}},
+		{flag: flagUse{"-output-compress", *f.outputCompress}, needs: []flagUse{output}},
+		// -keep-versions relies on the versions of a key being listed newest
+		// first, which a manifest, for example one written with -sort, need
+		// not be.
+		{flag: keepVersions, conflicts: []flagUse{fromManifest}},
+		{flag: validateDelete, conflicts: []flagUse{dryRun, simulate, listOnly, tagForDeletion, deleteWhileListing, resumeFile}},
+		{flag: tagForDeletion, conflicts: []flagUse{simulate}},
+		{flag: showSummaryOnly, conflicts: []flagUse{showObjects}},
+		{flag: simulate, conflicts: []flagUse{dryRun}},
+		{flag: checkLocks, needs: []flagUse{dryRun}},
+		{flag: checksumVerify, conflicts: []flagUse{fromManifest}, needs: []flagUse{dryRun}},
+		{flag: tag, conflicts: []flagUse{deleteWhileListing, resumeFile, formatCount}},
+		{flag: deleteMarkersOnly, conflicts: []flagUse{{"-skip-delete-markers", *f.skipDeleteMarkers}, tagForDeletion, storageClass, tag, currentOnly,
+			keepVersions, minSize, fromManifest, abortUploads}},
+		{flag: noncurrentOnly, conflicts: []flagUse{deleteMarkersOnly, keepVersions, fromManifest}},
+		{flag: currentOnly, conflicts: []flagUse{deleteMarkersOnly, noncurrentOnly, keepVersions, fromManifest, tagForDeletion, failedOutput}},
+		{flag: flagUse{"-yes", *f.yes}, needs: []flagUse{reviewThenDelete}},
+		{flag: reviewThenDelete, conflicts: []flagUse{dryRun, simulate, listOnly, selectObjects, streamResults, deleteWhileListing, resumeFile, validateDelete}},
+		{flag: streamResults, conflicts: []flagUse{dryRun, showObjects, showSummaryOnly, selectObjects}, why: " as both write to stdout"},
+		{flag: deleteWhileListing, conflicts: streamingConflicts},
+		{flag: resumeFile, conflicts: append(streamingConflicts, manyBuckets)},
+		{flag: flagUse{"-archive-bucket", *f.archiveBucket != ""}, conflicts: []flagUse{tagForDeletion, simulate, listOnly}},
+		{flag: flagUse{"-clear-config", *f.clearConfig}, conflicts: []flagUse{tagForDeletion, listOnly}},
+		{flag: listOnly, conflicts: []flagUse{dryRun, simulate, selectObjects, fromManifest, tagForDeletion, abortUploads, streamResults}},
+		{flag: output, conflicts: []flagUse{manyBuckets}},
+		{flag: fromManifest, conflicts: []flagUse{manyBuckets}},
+		{flag: selectObjects, conflicts: []flagUse{manyBuckets}},
+		{flag: failedOutput, conflicts: []flagUse{manyBuckets}},
+		{flag: fromManifest, conflicts: []flagUse{prefix, prefixFile, {"-glob", *f.glob != ""}, {"-include", len(f.include) > 0}, {"-exclude", len(f.exclude) > 0},
+			{"-include-regex", *f.includeRegex != ""}, {"-exclude-regex", *f.excludeRegex != ""}, storageClass, {"-older-than", *f.olderThan != ""},
+			{"-newer-than", *f.newerThan != ""}, minSize, {"-max-size", *f.maxSize != ""}, listShards}, why: " as the manifest already says what to delete"},
+		{flag: listShards, conflicts: []flagUse{prefix, prefixFile}},
+		{flag: flagUse{"-discover-prefixes", *f.discoverPrefixes}, conflicts: []flagUse{prefixFile, listShards, fromManifest, deleteWhileListing, resumeFile}},
+		{flag: flagUse{"-credential-source", *f.credentialSource != "default"}, conflicts: []flagUse{accessKeyId, secretAccessKey, sessionToken, credentialsFile}},
+		{flag: credentialsFile, conflicts: []flagUse{accessKeyId, secretAccessKey, sessionToken}},
+	}
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"flag"
+	"io"
+	"testing"
+)
+
+func parseFlags(t *testing.T, args ...string) *runFlags {
+	t.Helper()
+	fs := flag.NewFlagSet("empty-s3-bucket", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	flags := defineFlags(fs)
+	if err := fs.Parse(args); err != nil {
+		t.Fatalf("parsing %v failed: %s", args, err)
+	}
+	return flags
+}
+
+func TestValidateFlags(t *testing.T) {
+	tests := []struct {
+		name    string
+		args    []string
+		buckets []string
+		want    string
+	}{
+		{"defaults", nil, []string{"a"}, ""},
+		{"only the conflicts used are named", []string{"-list-only", "-select", "-from-manifest", "m.json"}, []string{"a"}, "-list-only can not be used with -select or -from-manifest"},
+		{"a single conflict", []string{"-simulate", "-dry-run"}, []string{"a"}, "-simulate can not be used with -dry-run"},
+		{"the reason is added", []string{"-stream-results", "-dry-run"}, []string{"a"}, "-stream-results can not be used with -dry-run as both write to stdout"},
+		{"needs one flag", []string{"-yes"}, []string{"a"}, "-yes can only be used with -review-then-delete"},
+		{"needs one of several flags", []string{"-format", "count"}, []string{"a"}, "-format count can only be used with -dry-run or -list-only"},
+		{"need is met", []string{"-format", "count", "-list-only"}, []string{"a"}, ""},
+		{"below the minimum", []string{"-concurrency", "0"}, []string{"a"}, "-concurrency must be at least 1"},
+		{"negative", []string{"-max-retries", "-1"}, []string{"a"}, "-max-retries can not be negative"},
+		{"minimums are checked first", []string{"-batch-size", "0", "-yes"}, []string{"a"}, "-batch-size must be at least 1"},
+		{"single bucket flag with one bucket", []string{"-output", "list.json"}, []string{"a"}, ""},
+		{"single bucket flag with many buckets", []string{"-output", "list.json"}, []string{"a", "b"}, "-output can not be used with more than one bucket"},
+		{"resume file with many buckets", []string{"-resume-file", "resume.json"}, []string{"a", "b"}, "-resume-file can not be used with more than one bucket"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := parseFlags(t, tt.args...).validate(tt.buckets)
+			got := ""
+			if err != nil {
+				got = err.Error()
+			}
+			if got != tt.want {
+				t.Fatalf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestJoinFlags(t *testing.T) {
+	tests := []struct {
+		names []string
+		want  string
+	}{
+		{[]string{"-a"}, "-a"},
+		{[]string{"-a", "-b"}, "-a or -b"},
+		{[]string{"-a", "-b", "-c"}, "-a, -b or -c"},
+	}
+	for _, tt := range tests {
+		if got := joinFlags(tt.names); got != tt.want {
+			t.Errorf("joinFlags(%v) = %q, want %q", tt.names, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+var formatModified = time.Date(2023, 1, 2, 3, 4, 5, 0, time.UTC)
+
+// formatList has a version and a delete marker whose key needs quoting in
+// csv.
+func formatList() *objectList {
+	list := newObjectList()
+	list.add(object{Key: "a.txt", VersionId: "v1", Size: 10, LastModified: &formatModified, StorageClass: "STANDARD", IsLatest: true})
+	list.appendDeleteMarkers([]*s3.DeleteMarkerEntry{
+		{Key: aws.String("b, c"), VersionId: aws.String("v2"), LastModified: &formatModified, IsLatest: aws.Bool(true)},
+	})
+	return list
+}
+
+func TestToCSV(t *testing.T) {
+	want := "Key,VersionId,IsLatest,Size,LastModified,StorageClass,Type\n" +
+		"a.txt,v1,true,10,2023-01-02T03:04:05Z,STANDARD,version\n" +
+		"\"b, c\",v2,true,0,2023-01-02T03:04:05Z,,delete-marker"
+	if got := formatList().toCSV(); got != want {
+		t.Fatalf("got\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestToCSVUnknownLastModified(t *testing.T) {
+	list := newObjectList()
+	list.add(object{Key: "a", VersionId: "v1", Size: 1})
+	want := "Key,VersionId,IsLatest,Size,LastModified,StorageClass,Type\na,v1,false,1,,,version"
+	if got := list.toCSV(); got != want {
+		t.Fatalf("got\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestToYAML(t *testing.T) {
+	tests := []struct {
+		name string
+		json string
+		want string
+	}{
+		{"scalar", `"a"`, `"a"`},
+		{"fields keep their order", `{"Length":2,"Bucket":"a","Latest":true,"Gone":null}`,
+			"Length: 2\nBucket: \"a\"\nLatest: true\nGone: null"},
+		{"strings stay strings", `{"Key":"true","VersionId":"0123"}`, "Key: \"true\"\nVersionId: \"0123\""},
+		{"odd keys are quoted", `{"logs/":2,"yes":1,"1st":3}`, "\"logs/\": 2\n\"yes\": 1\n\"1st\": 3"},
+		{"empty values", `{"Objects":[],"PrefixCounts":{}}`, "Objects: []\nPrefixCounts: {}"},
+		{"mappings in a sequence", `{"Objects":[{"Key":"a","Size":1},{"Key":"b","Size":2}]}`,
+			"Objects:\n  - Key: \"a\"\n    Size: 1\n  - Key: \"b\"\n    Size: 2"},
+		{"nested mapping", `{"Summary":{"Versions":1}}`, "Summary:\n  Versions: 1"},
+		{"top level sequence", `[1,[],["a"]]`, "- 1\n- []\n-\n  - \"a\""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := toYAML([]byte(tt.json))
+			if err != nil {
+				t.Fatalf("failed: %s", err)
+			}
+			if got != tt.want {
+				t.Fatalf("got\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestToYAMLInvalid(t *testing.T) {
+	if got, err := toYAML([]byte(`{"Key":`)); err == nil {
+		t.Fatalf("got %q, want an error", got)
+	}
+}
+
+func TestToTable(t *testing.T) {
+	want := "KEY    VERSION  SIZE           AGE\n" +
+		"a.txt  v1       10 B           2h\n" +
+		"b, c   v2       delete marker  2h\n" +
+		"\nTotal: 1 versions, 1 delete markers, 10 B"
+	if got := formatList().toTable(formatModified.Add(2 * time.Hour)); got != want {
+		t.Fatalf("got\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestAgeOf(t *testing.T) {
+	now := time.Date(2023, 6, 15, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		ago  time.Duration
+		want string
+	}{
+		{0, "0m"},
+		{59 * time.Minute, "59m"},
+		{time.Hour, "1h"},
+		{47 * time.Hour, "47h"},
+		{48 * time.Hour, "2d"},
+		{90 * 24 * time.Hour, "90d"},
+	}
+	for _, tt := range tests {
+		modified := now.Add(-tt.ago)
+		if got := ageOf(now, &modified); got != tt.want {
+			t.Errorf("ageOf %s ago = %s, want %s", tt.ago, got, tt.want)
+		}
+	}
+	if got := ageOf(now, nil); got != "-" {
+		t.Errorf("ageOf an unknown time = %s, want -", got)
+	}
+}
+
+func TestTemplateWriter(t *testing.T) {
+	tmpl, err := parseLineTemplate("{{.Key}} {{.VersionId}} {{.Size}} {{.Type}}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	buf := &strings.Builder{}
+	if err := formatList().lines(templateWriter(buf, tmpl)); err != nil {
+		t.Fatalf("failed: %s", err)
+	}
+	want := "a.txt v1 10 version\nb, c v2 0 delete-marker\n"
+	if buf.String() != want {
+		t.Fatalf("got\n%s\nwant\n%s", buf.String(), want)
+	}
+}
+
+func TestTemplateWriterUnknownField(t *testing.T) {
+	tmpl, err := parseLineTemplate("{{.Nope}}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := templateWriter(&strings.Builder{}, tmpl)(versionLine(object{Key: "a"})); err == nil {
+		t.Fatal("want an error for an unknown field")
+	}
+}
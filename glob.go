@@ -0,0 +1,138 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// patternList is a flag that can be given more than once, each use adds a
+// pattern.
+type patternList []string
+
+func (p *patternList) String() string {
+	return strings.Join(*p, " ")
+}
+
+func (p *patternList) Set(value string) error {
+	*p = append(*p, value)
+	return nil
+}
+
+// globToRegexp converts a glob to a regular expression matching whole keys.
+// "*" and "?" do not match "/", "**" matches across "/" and "**/" also
+// matches no directories at all, so backups/**/old-* matches backups/old-1.
+// [abc], [a-z] and the negated [!abc] or [^abc] character classes are
+// supported, a negated class never matches "/". A "\" matches the character
+// after it literally.
+func globToRegexp(glob string) (*regexp.Regexp, error) {
+	var b strings.Builder
+	b.WriteString("(?s)^")
+	runes := []rune(glob)
+	for i := 0; i < len(runes); i++ {
+		switch c := runes[i]; {
+		case c == '*' && i+1 < len(runes) && runes[i+1] == '*':
+			if i+2 < len(runes) && runes[i+2] == '/' {
+				b.WriteString("(?:.*/)?")
+				i += 2
+			} else {
+				b.WriteString(".*")
+				i++
+			}
+		case c == '*':
+			b.WriteString("[^/]*")
+		case c == '?':
+			b.WriteString("[^/]")
+		case c == '\\':
+			if i+1 == len(runes) {
+				return nil, fmt.Errorf("%q ends with a \\", glob)
+			}
+			i++
+			b.WriteString(regexp.QuoteMeta(string(runes[i])))
+		case c == '[':
+			class, n, err := globClass(runes[i+1:])
+			if err != nil {
+				return nil, fmt.Errorf("%q %s", glob, err)
+			}
+			b.WriteString(class)
+			i += n
+		default:
+			b.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	b.WriteString("$")
+	return regexp.Compile(b.String())
+}
+
+// globClass converts the character class at the start of runes, just after
+// its "[", to a regular expression. It returns how many runes it used,
+// including the closing "]".
+func globClass(runes []rune) (string, int, error) {
+	var b strings.Builder
+	b.WriteString("[")
+	i := 0
+	if len(runes) > 0 && (runes[0] == '!' || runes[0] == '^') {
+		b.WriteString("^/")
+		i++
+	}
+	start := i
+	for ; i < len(runes) && runes[i] != ']'; i++ {
+		c := runes[i]
+		switch {
+		case c == '\\' && i+1 < len(runes):
+			i++
+			c = runes[i]
+		case c == '-' && i > start && i+1 < len(runes) && runes[i+1] != ']':
+			b.WriteString("-")
+			continue
+		}
+		b.WriteString(regexp.QuoteMeta(string(c)))
+	}
+	if i == len(runes) {
+		return "", 0, fmt.Errorf("has an unclosed [")
+	}
+	if i == start {
+		return "", 0, fmt.Errorf("has an empty []")
+	}
+	b.WriteString("]")
+	return b.String(), i + 1, nil
+}
+
+// newGlobListFilter matches keys that match any of the include globs, when
+// there are some, and none of the exclude globs.
+func newGlobListFilter(include, exclude []string) (keyFilter, error) {
+	compile := func(globs []string) ([]*regexp.Regexp, error) {
+		res := make([]*regexp.Regexp, 0, len(globs))
+		for _, glob := range globs {
+			re, err := globToRegexp(glob)
+			if err != nil {
+				return nil, err
+			}
+			res = append(res, re)
+		}
+		return res, nil
+	}
+	includes, err := compile(include)
+	if err != nil {
+		return nil, err
+	}
+	excludes, err := compile(exclude)
+	if err != nil {
+		return nil, err
+	}
+
+	anyMatch := func(res []*regexp.Regexp, key string) bool {
+		for _, re := range res {
+			if re.MatchString(key) {
+				return true
+			}
+		}
+		return false
+	}
+	return func(key string) bool {
+		if len(includes) > 0 && !anyMatch(includes, key) {
+			return false
+		}
+		return !anyMatch(excludes, key)
+	}, nil
+}
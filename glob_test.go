@@ -0,0 +1,104 @@
+package main
+
+import "testing"
+
+func TestGlobToRegexp(t *testing.T) {
+	tests := []struct {
+		glob  string
+		key   string
+		match bool
+	}{
+		{"cache/*/tmp-*", "cache/a/tmp-1", true},
+		{"cache/*/tmp-*", "cache/a/b/tmp-1", false},
+		{"logs/?.txt", "logs/a.txt", true},
+		{"logs/?.txt", "logs/ab.txt", false},
+		{"logs/?", "logs//", false},
+		{"backups/**/old-*", "backups/old-1", true},
+		{"backups/**/old-*", "backups/2020/01/old-1", true},
+		{"backups/**/old-*", "backups/2020/new-1", false},
+		{"**", "a/b/c", true},
+		{"**/keep-*", "keep-1", true},
+		{"**/keep-*", "a/b/keep-1", true},
+		{"a.b", "axb", false},
+		{"a+(b)", "a+(b)", true},
+
+		// Keys are UTF-8, each character is one rune of the glob.
+		{"données/*", "données/a", true},
+		{"données/*", "donnees/a", false},
+		{"日本/?", "日本/語", true},
+		{"*é", "café", true},
+		{"[é]", "é", true},
+		{"[!é]x", "éx", false},
+
+		// Keys can hold a newline.
+		{"**", "a\nb", true},
+		{"a/**", "a/b\nc/d", true},
+		{"a*b", "a\nb", true},
+		{"a?b", "a\nb", true},
+
+		// Character classes.
+		{"[abc].log", "b.log", true},
+		{"[abc].log", "d.log", false},
+		{"[a-c].log", "b.log", true},
+		{"[a-c].log", "x.log", false},
+		{"[!abc].log", "d.log", true},
+		{"[!abc].log", "a.log", false},
+		{"[^abc].log", "d.log", true},
+		{"a[!x]b", "a/b", false},
+		{"a[^x]b", "a/b", false},
+		{"[-a]", "-", true},
+		{"[a-]", "-", true},
+		{"[\\]]", "]", true},
+
+		// A backslash matches the next character literally.
+		{"\\*", "*", true},
+		{"\\*", "a", false},
+		{"a\\?", "a?", true},
+	}
+	for _, tt := range tests {
+		re, err := globToRegexp(tt.glob)
+		if err != nil {
+			t.Errorf("globToRegexp(%q) failed: %s", tt.glob, err)
+			continue
+		}
+		if got := re.MatchString(tt.key); got != tt.match {
+			t.Errorf("globToRegexp(%q) matching %q = %v, want %v", tt.glob, tt.key, got, tt.match)
+		}
+	}
+}
+
+func TestGlobToRegexpInvalid(t *testing.T) {
+	for _, glob := range []string{"[abc", "a[]", "a\\", "[z-a]"} {
+		if _, err := globToRegexp(glob); err == nil {
+			t.Errorf("globToRegexp(%q) did not fail", glob)
+		}
+	}
+}
+
+func TestGlobListFilter(t *testing.T) {
+	filter, err := newGlobListFilter([]string{"données/**"}, []string{"**/garder-*"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for key, want := range map[string]bool{
+		"données/a":          true,
+		"données/b/c":        true,
+		"données/garder-1":   false,
+		"données/x/garder-2": false,
+		"autres/a":           false,
+	} {
+		if got := filter(key); got != want {
+			t.Errorf("filter(%q) = %v, want %v", key, got, want)
+		}
+	}
+}
+
+func TestGlobFilterUsesTheSameGlobs(t *testing.T) {
+	filter, err := newGlobFilter("backups/**/old-*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !filter("backups/2020/old-1") || filter("backups/2020/new-1") {
+		t.Error("-glob does not match like -include")
+	}
+}
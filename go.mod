@@ -2,6 +2,26 @@ module github.com/morfien101/empty-s3-bucket
 
 go 1.19
 
-require github.com/aws/aws-sdk-go v1.44.92
+require (
+	github.com/aws/aws-sdk-go v1.44.92
+	github.com/gdamore/tcell/v2 v2.5.4
+	github.com/mattn/go-runewidth v0.0.14
+	github.com/xitongsys/parquet-go v1.6.2
+)
 
-require github.com/jmespath/go-jmespath v0.4.0 // indirect
+require (
+	github.com/apache/arrow/go/arrow v0.0.0-20200730104253-651201b0f516 // indirect
+	github.com/apache/thrift v0.14.2 // indirect
+	github.com/gdamore/encoding v1.0.0 // indirect
+	github.com/golang/snappy v0.0.3 // indirect
+	github.com/jmespath/go-jmespath v0.4.0 // indirect
+	github.com/klauspost/compress v1.13.1 // indirect
+	github.com/lucasb-eyer/go-colorful v1.2.0 // indirect
+	github.com/pierrec/lz4/v4 v4.1.8 // indirect
+	github.com/rivo/uniseg v0.2.0 // indirect
+	github.com/xitongsys/parquet-go-source v0.0.0-20200817004010-026bad9b25d0 // indirect
+	golang.org/x/sys v0.0.0-20220722155257-8c9f86f7a55f // indirect
+	golang.org/x/term v0.0.0-20210927222741-03fcf44c2211 // indirect
+	golang.org/x/text v0.5.0 // indirect
+	golang.org/x/xerrors v0.0.0-20191204190536-9bdfabe68543 // indirect
+)
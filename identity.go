@@ -0,0 +1,55 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/credentials/ssocreds"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/sso"
+	"github.com/aws/aws-sdk-go/service/sts"
+)
+
+// verifyCredentials resolves the credentials by asking STS who we are. This
+// makes credential problems show up before any work on the bucket is done.
+func verifyCredentials(awsSession *session.Session) (*sts.GetCallerIdentityOutput, error) {
+	identity, err := sts.New(awsSession).GetCallerIdentity(&sts.GetCallerIdentityInput{})
+	if err != nil {
+		return nil, &AuthError{Err: err}
+	}
+	return identity, nil
+}
+
+// credentialsErrorHint returns advice for credential failures that have a
+// known fix. An empty string means there is nothing better to say than the
+// error itself.
+func credentialsErrorHint(err error, profile string) string {
+	if hasErrorCode(err, ssocreds.ErrCodeSSOProviderInvalidToken, sso.ErrCodeUnauthorizedException) {
+		login := "aws sso login"
+		if profile != "" {
+			login = fmt.Sprintf("aws sso login --profile %s", profile)
+		}
+		return fmt.Sprintf("Your AWS SSO session has expired or is invalid. Run '%s' and try again.", login)
+	}
+	return ""
+}
+
+// hasErrorCode walks the chain of AWS errors looking for any of the codes.
+func hasErrorCode(err error, codes ...string) bool {
+	var aerr awserr.Error
+	if !errors.As(err, &aerr) {
+		return false
+	}
+	for err = aerr; err != nil; {
+		aerr, ok := err.(awserr.Error)
+		if !ok {
+			return false
+		}
+		if contains(codes, aerr.Code()) {
+			return true
+		}
+		err = aerr.OrigErr()
+	}
+	return false
+}
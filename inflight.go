@@ -0,0 +1,53 @@
+package main
+
+import "sync"
+
+// inFlightLimiter bounds how many listed objects are waiting to be deleted.
+// The lister acquires room for each page before handing it over, and the
+// deleter releases it once the page is done, so a lister that is faster than
+// the deletes blocks instead of filling memory.
+type inFlightLimiter struct {
+	lock     sync.Mutex
+	cond     *sync.Cond
+	max      int64
+	inFlight int64
+	closed   bool
+}
+
+// newInFlightLimiter allows up to max objects in flight, 0 means no limit.
+func newInFlightLimiter(max int64) *inFlightLimiter {
+	l := &inFlightLimiter{max: max}
+	l.cond = sync.NewCond(&l.lock)
+	return l
+}
+
+// acquire waits until n more objects fit. A single page bigger than the
+// limit is let through on its own so it can not block forever. It returns
+// false if the limiter was closed while waiting.
+func (l *inFlightLimiter) acquire(n int64) bool {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	for !l.closed && l.max > 0 && l.inFlight > 0 && l.inFlight+n > l.max {
+		l.cond.Wait()
+	}
+	if l.closed {
+		return false
+	}
+	l.inFlight += n
+	return true
+}
+
+func (l *inFlightLimiter) release(n int64) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	l.inFlight -= n
+	l.cond.Broadcast()
+}
+
+// close wakes anything waiting in acquire, used when the deleter stops early.
+func (l *inFlightLimiter) close() {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	l.closed = true
+	l.cond.Broadcast()
+}
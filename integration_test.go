@@ -0,0 +1,128 @@
+//go:build integration
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+// The integration tests run against LocalStack, or any S3 compatible
+// endpoint, and are skipped unless S3_TEST_ENDPOINT_URL is set, eg:
+//
+//	docker run --rm -d -p 4566:4566 localstack/localstack
+//	S3_TEST_ENDPOINT_URL=http://localhost:4566 go test -tags integration -run Integration .
+//
+// S3_TEST_OBJECTS changes the number of keys written, the default needs more
+// than one listing page and more than one delete batch.
+
+func integrationEndpoint(t *testing.T) string {
+	endpoint := os.Getenv("S3_TEST_ENDPOINT_URL")
+	if endpoint == "" {
+		t.Skip("S3_TEST_ENDPOINT_URL is not set")
+	}
+	for name, value := range map[string]string{"AWS_ACCESS_KEY_ID": "test", "AWS_SECRET_ACCESS_KEY": "test", "AWS_REGION": "us-east-1"} {
+		if os.Getenv(name) == "" {
+			t.Setenv(name, value)
+		}
+	}
+	return endpoint
+}
+
+// runMain runs the command as if it was given args. run uses the global flag
+// set, so it is replaced for each run.
+func runMain(t *testing.T, args ...string) int {
+	oldArgs, oldFlags := os.Args, flag.CommandLine
+	defer func() { os.Args, flag.CommandLine = oldArgs, oldFlags }()
+	os.Args = append([]string{"empty-s3-bucket"}, args...)
+	flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ContinueOnError)
+	return run()
+}
+
+func countRemaining(t *testing.T, client *s3.S3, bucket string) int {
+	remaining := 0
+	err := client.ListObjectVersionsPages(&s3.ListObjectVersionsInput{Bucket: aws.String(bucket)}, func(page *s3.ListObjectVersionsOutput, lastPage bool) bool {
+		remaining += len(page.Versions) + len(page.DeleteMarkers)
+		return true
+	})
+	if err != nil {
+		t.Fatalf("could not list %s: %s", bucket, err)
+	}
+	return remaining
+}
+
+// TestIntegrationEmptyVersionedBucket fills a versioned bucket with several
+// versions of some keys, delete markers and directory markers, empties it and
+// checks nothing is left.
+func TestIntegrationEmptyVersionedBucket(t *testing.T) {
+	endpoint := integrationEndpoint(t)
+	objects := 1200
+	if value := os.Getenv("S3_TEST_OBJECTS"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			t.Fatalf("invalid S3_TEST_OBJECTS: %s", err)
+		}
+		objects = n
+	}
+
+	client := s3.New(session.Must(session.NewSession(&aws.Config{
+		Endpoint:         aws.String(endpoint),
+		S3ForcePathStyle: aws.Bool(true),
+	})))
+	bucket := fmt.Sprintf("empty-s3-bucket-test-%d", time.Now().UnixNano())
+	if _, err := client.CreateBucket(&s3.CreateBucketInput{Bucket: aws.String(bucket)}); err != nil {
+		t.Fatalf("could not create %s: %s", bucket, err)
+	}
+	defer client.DeleteBucket(&s3.DeleteBucketInput{Bucket: aws.String(bucket)})
+	_, err := client.PutBucketVersioning(&s3.PutBucketVersioningInput{
+		Bucket:                  aws.String(bucket),
+		VersioningConfiguration: &s3.VersioningConfiguration{Status: aws.String(s3.BucketVersioningStatusEnabled)},
+	})
+	if err != nil {
+		t.Fatalf("could not turn on versioning: %s", err)
+	}
+
+	put := func(key string) {
+		_, err := client.PutObject(&s3.PutObjectInput{Bucket: aws.String(bucket), Key: aws.String(key), Body: strings.NewReader("test")})
+		if err != nil {
+			t.Fatalf("could not put %s: %s", key, err)
+		}
+	}
+	for i := 1; i <= objects; i++ {
+		put(fmt.Sprintf("data/file-%d.txt", i))
+	}
+	for i := 1; i <= 3; i++ {
+		put("data/file-1.txt")
+		put(fmt.Sprintf("dir-%d/", i))
+		put(fmt.Sprintf("dir-%d/nested/", i))
+		put(fmt.Sprintf("keys with spaces/ünïcödé & more %d.txt", i))
+		if _, err := client.DeleteObject(&s3.DeleteObjectInput{Bucket: aws.String(bucket), Key: aws.String(fmt.Sprintf("data/file-%d.txt", i+1))}); err != nil {
+			t.Fatalf("could not add a delete marker: %s", err)
+		}
+	}
+	before := countRemaining(t, client, bucket)
+
+	code := runMain(t,
+		"-bucket-name", bucket,
+		"-endpoint-url", endpoint,
+		"-force-path-style",
+		"-no-region-autodetect",
+		"-ignore-lifecycle",
+		"-quiet",
+	)
+	if code != 0 {
+		t.Fatalf("exit code %d, want 0", code)
+	}
+	if after := countRemaining(t, client, bucket); after != 0 {
+		t.Fatalf("%d of %d versions and delete markers are left in %s", after, before, bucket)
+	}
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+// lockCheckWorkers is the number of HeadObject calls allowed in flight while
+// checking locks. The rate limit is what really controls the pace.
+const lockCheckWorkers = 8
+
+// checkLocks annotates every object version in the list with its Object Lock
+// retention and legal hold status. It costs one HeadObject call per version
+// and is limited to ratePerSecond calls a second. Delete markers can not be
+// locked so are skipped. The SSE-C key is only needed for SSE-C objects.
+// The notice that checking has started goes to info.
+func checkLocks(info io.Writer, awsSession *session.Session, bucket string, list *objectList, ratePerSecond int, sseKey sseCustomerKey) []error {
+	s3Handler := s3.New(awsSession)
+
+	interval := time.Second / time.Duration(ratePerSecond)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	fmt.Fprintf(info, "Checking locks on %d objects at up to %d requests a second, this can take a while.\n", len(list.Objects), ratePerSecond)
+
+	work := make(chan *object)
+	errs := []error{}
+	errsLock := sync.Mutex{}
+	wg := sync.WaitGroup{}
+	for i := 0; i < lockCheckWorkers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for obj := range work {
+				if err := lookupLock(s3Handler, bucket, obj, sseKey); err != nil {
+					if hint := sseCustomerHint(err, sseKey); hint != "" {
+						err = fmt.Errorf("%s, %s", err, hint)
+					}
+					errsLock.Lock()
+					errs = append(errs, fmt.Errorf("%s (%s): %s", obj.Key, obj.VersionId, err))
+					errsLock.Unlock()
+				}
+			}
+		}()
+	}
+
+	for i := range list.Objects {
+		<-ticker.C
+		work <- &list.Objects[i]
+	}
+	close(work)
+	wg.Wait()
+
+	return errs
+}
+
+func lookupLock(s3Handler *s3.S3, bucket string, obj *object, sseKey sseCustomerKey) error {
+	input := &s3.HeadObjectInput{
+		Bucket:    aws.String(bucket),
+		Key:       aws.String(obj.Key),
+		VersionId: aws.String(obj.VersionId),
+	}
+	sseKey.applyToHead(input)
+	out, err := s3Handler.HeadObject(input)
+	if err != nil {
+		return err
+	}
+
+	obj.LockMode = aws.StringValue(out.ObjectLockMode)
+	obj.RetainUntil = out.ObjectLockRetainUntilDate
+	obj.LegalHold = aws.StringValue(out.ObjectLockLegalHoldStatus)
+	return nil
+}
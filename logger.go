@@ -0,0 +1,200 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+type logLevel int
+
+const (
+	levelDebug logLevel = iota
+	levelInfo
+	levelWarn
+	levelError
+)
+
+// logLevelNames are the values accepted by -log-level, in level order.
+var logLevelNames = []string{"debug", "info", "warn", "error"}
+
+// logFormats are the values accepted by -log-format.
+var logFormats = []string{"text", "json"}
+
+func parseLogLevel(name string) (logLevel, error) {
+	for i, n := range logLevelNames {
+		if strings.EqualFold(n, name) {
+			return logLevel(i), nil
+		}
+	}
+	return 0, fmt.Errorf("%s is not a log level, use one of %s", name, strings.Join(logLevelNames, ","))
+}
+
+func (l logLevel) String() string {
+	return logLevelNames[l]
+}
+
+// logField is a named value added to a log line.
+type logField struct {
+	key   string
+	value interface{}
+}
+
+func field(key string, value interface{}) logField {
+	return logField{key: key, value: value}
+}
+
+// logSink is somewhere log lines are written, with its own format and
+// level.
+type logSink struct {
+	w     io.Writer
+	json  bool
+	level logLevel
+}
+
+// eventLogger writes a line for each event to every sink whose level it is at
+// or above, either as text or as a JSON object per line. It is safe to use
+// from many goroutines. A nil eventLogger logs nothing, so callers do not
+// need to check if logging is turned on.
+type eventLogger struct {
+	lock  sync.Mutex
+	sinks []logSink
+}
+
+func (l *eventLogger) addSink(w io.Writer, format string, level logLevel) {
+	l.sinks = append(l.sinks, logSink{w: w, json: format == "json", level: level})
+}
+
+func (l *eventLogger) log(level logLevel, msg string, fields ...logField) {
+	if l == nil {
+		return
+	}
+	now := time.Now().UTC().Format(time.RFC3339Nano)
+
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	for _, sink := range l.sinks {
+		if level >= sink.level {
+			io.WriteString(sink.w, formatLogLine(sink.json, now, level, msg, fields))
+		}
+	}
+}
+
+func formatLogLine(asJSON bool, now string, level logLevel, msg string, fields []logField) string {
+	buf := &strings.Builder{}
+	if asJSON {
+		// The fields are written by hand to keep time, level and msg first.
+		fmt.Fprintf(buf, `{"time":%q,"level":%q,"msg":%s`, now, level, jsonValue(msg))
+		for _, f := range fields {
+			fmt.Fprintf(buf, ",%s:%s", jsonValue(f.key), jsonValue(f.value))
+		}
+		buf.WriteString("}\n")
+	} else {
+		fmt.Fprintf(buf, "%s %-5s %s", now, strings.ToUpper(level.String()), msg)
+		for _, f := range fields {
+			value := fmt.Sprint(f.value)
+			if value == "" || strings.ContainsAny(value, " \t\"=") {
+				value = fmt.Sprintf("%q", value)
+			}
+			fmt.Fprintf(buf, " %s=%s", f.key, value)
+		}
+		buf.WriteString("\n")
+	}
+	return buf.String()
+}
+
+func (l *eventLogger) debug(msg string, fields ...logField) { l.log(levelDebug, msg, fields...) }
+func (l *eventLogger) info(msg string, fields ...logField)  { l.log(levelInfo, msg, fields...) }
+func (l *eventLogger) warn(msg string, fields ...logField)  { l.log(levelWarn, msg, fields...) }
+func (l *eventLogger) error(msg string, fields ...logField) { l.log(levelError, msg, fields...) }
+
+// logWriter turns the plain text messages written to it into log lines, so
+// nothing but JSON is printed with -log-format json. Each Write is one
+// message. Messages starting with "WARNING: " are logged as warnings, the
+// rest at level.
+type logWriter struct {
+	log   *eventLogger
+	level logLevel
+}
+
+func (w logWriter) Write(p []byte) (int, error) {
+	msg := strings.TrimRight(string(p), "\n")
+	if msg == "" {
+		return len(p), nil
+	}
+	level := w.level
+	if strings.HasPrefix(msg, "WARNING: ") {
+		level, msg = levelWarn, strings.TrimPrefix(msg, "WARNING: ")
+	}
+	w.log.log(level, msg)
+	return len(p), nil
+}
+
+func jsonValue(v interface{}) string {
+	if err, ok := v.(error); ok {
+		v = err.Error()
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		b, _ = json.Marshal(fmt.Sprint(v))
+	}
+	return string(b)
+}
+
+// logObserver logs the listing and every delete batch of a bucket. Each
+// object that fails is logged on its own as a warning.
+type logObserver struct {
+	NopObserver
+	log    *eventLogger
+	bucket string
+}
+
+func (o logObserver) OnListPage(page *s3.ListObjectVersionsOutput) {
+	o.log.debug("listed page", field("bucket", o.bucket), field("versions", len(page.Versions)), field("deleteMarkers", len(page.DeleteMarkers)))
+}
+
+func (o logObserver) OnBatchStart(event BatchEvent) {
+	o.log.debug("sending batch", field("bucket", o.bucket), field("batch", event.Index), field("objects", len(event.Objects)))
+}
+
+func (o logObserver) OnBatchDeleted(event BatchEvent) {
+	o.log.info("batch deleted", field("bucket", o.bucket), field("batch", event.Index), field("deleted", event.Deleted),
+		field("failed", len(event.Errors)), field("durationMs", event.Duration.Milliseconds()), field("requestId", event.RequestId))
+	for _, e := range event.Errors {
+		o.log.warn("object not deleted", field("bucket", o.bucket), field("key", aws.StringValue(e.Key)), field("versionId", aws.StringValue(e.VersionId)),
+			field("code", aws.StringValue(e.Code)), field("error", aws.StringValue(e.Message)))
+	}
+}
+
+func (o logObserver) OnBatchError(event BatchEvent, err error) {
+	o.log.error("batch failed", field("bucket", o.bucket), field("batch", event.Index), field("objects", len(event.Objects)),
+		field("requestId", event.RequestId), field("error", err))
+}
+
+// logReport logs how a bucket finished.
+func logReport(log *eventLogger, report *runReport) {
+	fields := []logField{
+		field("bucket", report.Bucket),
+		field("exitCode", report.ExitCode),
+		field("objectsDeleted", report.ObjectsDeleted),
+		field("deleteMarkersDeleted", report.DeleteMarkersDeleted),
+		field("directoriesDeleted", report.DirectoriesDeleted),
+		field("deleteMarkersCreated", report.DeleteMarkersCreated),
+		field("failures", report.DeleteFailures),
+		field("durationMs", report.EndTime.Sub(report.StartTime).Milliseconds()),
+	}
+	if report.Success {
+		log.info("bucket finished", fields...)
+		return
+	}
+	for _, e := range report.Errors {
+		log.error("bucket error", field("bucket", report.Bucket), field("error", e))
+	}
+	log.error("bucket failed", fields...)
+}
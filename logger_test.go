@@ -0,0 +1,31 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestLogWriter(t *testing.T) {
+	buf := &bytes.Buffer{}
+	logger := &eventLogger{}
+	logger.addSink(buf, "json", levelWarn)
+	w := logWriter{log: logger, level: levelInfo}
+
+	w.Write([]byte("Attempting to delete 10 objects\n"))
+	w.Write([]byte("WARNING: bucket 'b' has lifecycle rules\n"))
+	w.Write([]byte("\n"))
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 1 {
+		t.Fatalf("got %d lines, want only the warning: %q", len(lines), buf.String())
+	}
+	line := map[string]interface{}{}
+	if err := json.Unmarshal([]byte(lines[0]), &line); err != nil {
+		t.Fatalf("%q is not JSON: %s", lines[0], err)
+	}
+	if line["level"] != "warn" || line["msg"] != "bucket 'b' has lifecycle rules" {
+		t.Errorf("got %v, want the warning without its prefix", line)
+	}
+}
@@ -2,35 +2,96 @@ package main
 
 import (
 	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
+	"io"
 	"os"
 	"regexp"
 	"sort"
 	"strings"
 	"sync"
+	"text/template"
+	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/endpoints"
+	"github.com/aws/aws-sdk-go/aws/request"
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/s3"
 )
 
-var VALID_FORMATS = []string{"json", "pretty-json"}
+var VALID_FORMATS = []string{"json", "pretty-json", "ndjson", "yaml", "csv", "table", "template", "parquet", "count"}
+
+// maxAWSBatchSize is the most keys AWS accepts in one DeleteObjects request.
+const maxAWSBatchSize = 1000
+
+// maxDeleteRequestBytes is a conservative cap on the XML body of a
+// DeleteObjects request. 1000 keys near the 1024 byte key limit would
+// otherwise make a request large enough for S3 to reject it.
+const maxDeleteRequestBytes = 1 << 20
+
+// identifierOverhead is the size of the XML wrapped around each key and
+// version id in a DeleteObjects request body.
+const identifierOverhead = len("<Object><Key></Key><VersionId></VersionId></Object>")
+
 var version = "development"
 
 type object struct {
 	Key       string `json:"Key"`
 	VersionId string `json:"VersionId"`
+	Size      int64  `json:"Size"`
+	// LastModified, StorageClass and IsLatest are not known for objects read
+	// from older manifests.
+	LastModified *time.Time `json:"LastModified,omitempty"`
+	StorageClass string     `json:"StorageClass,omitempty"`
+	IsLatest     bool       `json:"IsLatest,omitempty"`
+	// Lock details are only filled in by -check-locks.
+	LockMode    string     `json:"LockMode,omitempty"`
+	RetainUntil *time.Time `json:"RetainUntil,omitempty"`
+	LegalHold   string     `json:"LegalHold,omitempty"`
+	// ETag and Owner are only filled in by -checksum-verify. Owner is the
+	// canonical user id.
+	ETag  string `json:"ETag,omitempty"`
+	Owner string `json:"Owner,omitempty"`
+}
+
+func newObject(key, versionId string, size int64) object {
+	return object{Key: key, VersionId: versionId, Size: size}
 }
 
-func newObject(key, versionId string) object {
-	return object{Key: key, VersionId: versionId}
+// objectFromVersion keeps the details of a listed version that are shown in
+// the listing.
+func objectFromVersion(v *s3.ObjectVersion) object {
+	obj := newObject(aws.StringValue(v.Key), aws.StringValue(v.VersionId), aws.Int64Value(v.Size))
+	obj.LastModified = v.LastModified
+	obj.StorageClass = aws.StringValue(v.StorageClass)
+	obj.IsLatest = aws.BoolValue(v.IsLatest)
+	return obj
 }
 
+// objectList is the result of a listing.
+//
+// add, appendDeleteMarkers, merge and dropDeleteMarkers are safe to call from
+// many goroutines at once. Reading the exported fields directly, including
+// marshalling the list, is only safe once every writer has finished. The
+// list must not be copied once in use.
 type objectList struct {
+	lock sync.Mutex
+
+	// Bucket and GeneratedAt record where and when the listing was made.
+	Bucket        string                  `json:"Bucket,omitempty"`
+	GeneratedAt   *time.Time              `json:"GeneratedAt,omitempty"`
 	ObjectCount   int64                   `json:"Length"`
 	Objects       []object                `json:"Objects"`
 	DeleteMarkers []*s3.DeleteMarkerEntry `json:"DeleteMarkers"`
+	// MultipartUploads is only set in a dry run with -abort-multipart-uploads.
+	MultipartUploads []multipartUpload `json:"MultipartUploads,omitempty"`
+	// PrefixCounts is only set when listing by prefixes.
+	PrefixCounts map[string]int64 `json:"PrefixCounts,omitempty"`
+	// scanned counts everything listed, including what the filters dropped.
+	// It is only written by the goroutine doing the listing.
+	scanned int64
 }
 
 func newObjectList() *objectList {
@@ -41,22 +102,56 @@ func newObjectList() *objectList {
 	}
 }
 
-func (objList *objectList) add(key, versionId string) {
+func (objList *objectList) add(obj object) {
+	objList.lock.Lock()
+	defer objList.lock.Unlock()
 	objList.ObjectCount++
-	objList.Objects = append(objList.Objects, newObject(key, versionId))
+	objList.Objects = append(objList.Objects, obj)
 }
 
 func (objList *objectList) appendDeleteMarkers(deleteMarkers []*s3.DeleteMarkerEntry) {
+	objList.lock.Lock()
+	defer objList.lock.Unlock()
 	objList.ObjectCount = objList.ObjectCount + int64(len(deleteMarkers))
 	objList.DeleteMarkers = append(objList.DeleteMarkers, deleteMarkers...)
 }
 
+// dropDeleteMarkers removes the delete markers from the list and returns how
+// many there were.
+func (objList *objectList) dropDeleteMarkers() int64 {
+	objList.lock.Lock()
+	defer objList.lock.Unlock()
+	dropped := int64(len(objList.DeleteMarkers))
+	objList.ObjectCount -= dropped
+	objList.DeleteMarkers = make([]*s3.DeleteMarkerEntry, 0)
+	return dropped
+}
+
+// merge adds everything in other to the list. other must not be written to
+// while it is being merged.
+func (objList *objectList) merge(other *objectList) {
+	objList.lock.Lock()
+	defer objList.lock.Unlock()
+	objList.ObjectCount += other.ObjectCount
+	objList.scanned += other.scanned
+	objList.Objects = append(objList.Objects, other.Objects...)
+	objList.DeleteMarkers = append(objList.DeleteMarkers, other.DeleteMarkers...)
+}
+
 func (objList *objectList) toString(format string) string {
 	switch format {
 	case "json":
 		return objList.toJSON(false)
 	case "pretty-json":
 		return objList.toJSON(true)
+	case "yaml":
+		return marshalYAML(objList)
+	case "ndjson":
+		return objList.toNDJSON()
+	case "csv":
+		return objList.toCSV()
+	case "table":
+		return objList.toTable(time.Now())
 	}
 	// We should never get here as formats are checked BEFORE execution.
 	return ""
@@ -81,86 +176,849 @@ func contains(list []string, matcher string) bool {
 	return false
 }
 
+// splitList splits a comma separated flag value, dropping empty entries and
+// the spaces around each one.
+func splitList(value string) []string {
+	list := []string{}
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
 func main() {
-	flagBucketName := flag.String("bucket-name", "", "Name of the bucket to empty.")
-	flagProfile := flag.String("profile", "", "AWS Profile to use, if there is one.")
-	flagAWSRegion := flag.String("aws-region", "", "The region for the aws connection. If set it will override what is set in AWS_REGION. If there is no AWS_REGION, then eu-west-1 will be used.")
-	flagFormat := flag.String("format", "pretty-json", fmt.Sprintf("If -dry-run or -show-objects is used, the format of the output, %s are available.", strings.Join(VALID_FORMATS, ",")))
-	flagDryRun := flag.Bool("dry-run", false, "Show versions to be deleted.")
-	flagShowObjects := flag.Bool("show-objects", false, "Show the objects before attempting to delete them.")
-	flagVersion := flag.Bool("v", false, "Print the version.")
+	os.Exit(run())
+}
+
+func run() (exitCode int) {
+	flags := defineFlags(flag.CommandLine)
 
 	flag.Parse()
+	if err := applyEnvironment(flag.CommandLine); err != nil {
+		fmt.Println(err)
+		return 1
+	}
 
-	if *flagVersion {
+	if *flags.version {
 		fmt.Println(version)
-		return
+		return 0
+	}
+
+	if *flags.jsonSchema {
+		schema, err := manifestSchemaJSON()
+		if err != nil {
+			fmt.Printf("There was an error building the schema. Error: %s\n", err)
+			return 1
+		}
+		fmt.Println(schema)
+		return 0
+	}
+
+	correlationID := *flags.correlationID
+	if correlationID == "" {
+		var err error
+		correlationID, err = newCorrelationID()
+		if err != nil {
+			fmt.Printf("Could not generate a correlation id. Error: %s\n", err)
+			return 1
+		}
+		if !*flags.quiet {
+			fmt.Fprintf(os.Stderr, "Correlation id: %s\n", correlationID)
+		}
+	}
+
+	bucketArgs := splitList(*flags.bucketName)
+	if len(bucketArgs) > 0 && flag.NArg() > 0 {
+		fmt.Println("Give the buckets either with -bucket-name or as arguments, not both.")
+		return 1
+	}
+	if len(bucketArgs) == 0 {
+		bucketArgs = flag.Args()
+	}
+	buckets, uriPrefix, err := parseBucketArgs(bucketArgs)
+	if err != nil {
+		fmt.Printf("Invalid bucket: %s.\n", err)
+		return 1
+	}
+	if uriPrefix != "" {
+		if *flags.prefix != "" && *flags.prefix != uriPrefix {
+			fmt.Printf("The prefix %q in the s3:// URI does not match -prefix %q.\n", uriPrefix, *flags.prefix)
+			return 1
+		}
+		*flags.prefix = uriPrefix
+	}
+
+	reports := []*runReport{}
+	for _, bucket := range buckets {
+		reports = append(reports, newRunReport(bucket, correlationID))
+	}
+	if len(reports) == 0 {
+		reports = append(reports, newRunReport("", correlationID))
+	}
+	if *flags.report != "" {
+		defer func() {
+			// Buckets that were never reached, because a flag was wrong, take
+			// the exit code of the run.
+			for _, r := range reports {
+				if r.EndTime.IsZero() {
+					r.finish(exitCode)
+				}
+			}
+			if err := writeReports(*flags.report, reports); err != nil {
+				fmt.Fprintf(os.Stderr, "There was an error writing the report to %s. Error: %s\n", *flags.report, err)
+			}
+		}()
+	}
+
+	if err := validateCorrelationID(correlationID); err != nil {
+		fmt.Printf("Invalid -correlation-id: %s.\n", err)
+		return 1
 	}
 
-	if *flagBucketName == "" {
+	if len(buckets) == 0 {
 		fmt.Println("No Bucket name was given.")
 		flag.PrintDefaults()
-		os.Exit(1)
+		return 1
 	}
 
-	if *flagAWSRegion != "" {
-		os.Setenv("AWS_REGION", *flagAWSRegion)
+	allowPatterns, err := compileAllowPatterns(bucketAllowPattern, *flags.bucketAllowPattern)
+	if err != nil {
+		fmt.Printf("Invalid bucket allow pattern: %s.\n", err)
+		return 1
+	}
+	if refused := disallowedBuckets(buckets, allowPatterns); len(refused) > 0 {
+		fmt.Printf("Refusing to run, these buckets are not allowed by the bucket allow pattern: %s.\n", strings.Join(refused, ", "))
+		return 1
+	}
+
+	if *flags.awsRegion != "" {
+		os.Setenv("AWS_REGION", *flags.awsRegion)
 	}
 	if _, set := os.LookupEnv("AWS_REGION"); !set {
 		os.Setenv("AWS_REGION", "eu-west-1")
 	}
 
-	if !contains(VALID_FORMATS, *flagFormat) {
-		fmt.Printf("%s is not a valid format.", *flagFormat)
+	if !contains(VALID_FORMATS, *flags.format) {
+		fmt.Printf("%s is not a valid format.", *flags.format)
 		flag.PrintDefaults()
-		os.Exit(1)
+		return 1
 	}
 
-	awsSession, err := setupAwsSession(*flagProfile)
-	if err != nil {
-		fmt.Printf("There was an error getting your AWS Creds. Error: %s", err)
-		os.Exit(1)
+	if err := flags.validate(buckets); err != nil {
+		fmt.Printf("%s.\n", err)
+		return 1
 	}
-	awsSession.Config.CredentialsChainVerboseErrors = aws.Bool(true)
 
-	list, err := listObjects(awsSession, *flagBucketName)
-	if err != nil {
-		fmt.Printf("There was an error listing the objects for your specified bucket '%s'.\nError: %s\n", *flagBucketName, err)
-		os.Exit(1)
+	var lineTemplate *template.Template
+	if *flags.format == "template" {
+		var err error
+		lineTemplate, err = parseLineTemplate(*flags.template)
+		if err == nil {
+			// A field that does not exist only shows up when the template is
+			// run, so it is tried once on an empty line.
+			err = templateWriter(io.Discard, lineTemplate)(versionLine(object{LastModified: &time.Time{}}))
+		}
+		if err != nil {
+			fmt.Printf("Invalid -template: %s.\n", err)
+			return 1
+		}
 	}
 
-	if *flagDryRun || *flagShowObjects {
-		fmt.Println(list.toString(*flagFormat))
+	// -format ndjson and template are written as the bucket is listed unless
+	// something needs the whole listing first, then they are made from the
+	// listing like json.
+	streamListing := (*flags.format == "ndjson" || *flags.format == "template") && (*flags.dryRun || *flags.listOnly) && *flags.output == "" && *flags.fromManifest == "" && *flags.keepVersions == 0 &&
+		!*flags.selectObjects && !*flags.checkLocks && !*flags.checksumVerify && !*flags.breakdown && !*flags.showSummaryOnly && !*flags.abortMultipartUploads && !*flags.sort && *flags.tag == ""
+
+	if *flags.retryMaxDelay > 0 && *flags.retryMaxDelay < *flags.retryBaseDelay {
+		fmt.Println("-retry-max-delay can not be shorter than -retry-base-delay.")
+		return 1
 	}
 
-	if *flagDryRun {
-		return
+	// Both of these list and delete a page at a time, -resume-file also
+	// keeps a checkpoint.
+	streaming := *flags.deleteWhileListing || *flags.resumeFile != ""
+
+	if *flags.listShards < 1 || *flags.listShards > len(shardAlphabet) {
+		fmt.Printf("-list-shards must be between 1 and %d.\n", len(shardAlphabet))
+		return 1
+	}
+	if *flags.batchSize > maxAWSBatchSize && *flags.endpointURL == "" {
+		fmt.Printf("-batch-size can not be above %d for AWS S3. Larger batches are only allowed with -endpoint-url.\n", maxAWSBatchSize)
+		return 1
 	}
 
-	rawErrors, err := deleteObjects(awsSession, *flagBucketName, *list)
-	if err != nil {
-		fmt.Printf("There was an error deleting objects. Error: %s.", err)
-		fmt.Println("Raw Request Errors:")
-		for _, e := range rawErrors {
-			fmt.Println(e)
+	var deletionTag *s3.Tag
+	if *flags.tagForDeletion {
+		var err error
+		deletionTag, err = parseTag(*flags.deletionTag)
+		if err != nil {
+			fmt.Printf("Invalid -deletion-tag: %s.\n", err)
+			return 1
+		}
+	}
+
+	sseKey := sseCustomerKey{key: *flags.sseCustomerKey, keyMD5: *flags.sseCustomerKeyMD5}
+	if err := sseKey.validate(); err != nil {
+		fmt.Printf("Invalid SSE-C key: %s.\n", err)
+		return 1
+	}
+
+	if *flags.selectObjects && (!isTerminal(os.Stdin) || !isTerminal(os.Stdout)) {
+		fmt.Println("-select needs an interactive terminal.")
+		return 1
+	}
+
+	filters := []keyFilter{}
+	if *flags.glob != "" {
+		globFilter, err := newGlobFilter(*flags.glob)
+		if err != nil {
+			fmt.Printf("%s is not a valid glob pattern. Error: %s\n", *flags.glob, err)
+			return 1
+		}
+		filters = append(filters, globFilter)
+	}
+	if len(flags.include) > 0 || len(flags.exclude) > 0 {
+		globListFilter, err := newGlobListFilter(flags.include, flags.exclude)
+		if err != nil {
+			fmt.Printf("Invalid -include or -exclude pattern: %s.\n", err)
+			return 1
 		}
+		filters = append(filters, globListFilter)
 	}
+	if *flags.includeRegex != "" {
+		includeFilter, err := newRegexFilter(*flags.includeRegex, false)
+		if err != nil {
+			fmt.Printf("%s is not a valid -include-regex. Error: %s\n", *flags.includeRegex, err)
+			return 1
+		}
+		filters = append(filters, includeFilter)
+	}
+	if *flags.excludeRegex != "" {
+		excludeFilter, err := newRegexFilter(*flags.excludeRegex, true)
+		if err != nil {
+			fmt.Printf("%s is not a valid -exclude-regex. Error: %s\n", *flags.excludeRegex, err)
+			return 1
+		}
+		filters = append(filters, excludeFilter)
+	}
+
+	versionFilters := []versionFilter{}
+	if *flags.storageClass != "" {
+		storageClassFilter, err := newStorageClassFilter(splitList(*flags.storageClass))
+		if err != nil {
+			fmt.Printf("Invalid -storage-class: %s.\n", err)
+			return 1
+		}
+		versionFilters = append(versionFilters, storageClassFilter)
+	}
+
+	var filterTag *s3.Tag
+	if *flags.tag != "" {
+		var err error
+		filterTag, err = parseTag(*flags.tag)
+		if err != nil {
+			fmt.Printf("Invalid -tag: %s.\n", err)
+			return 1
+		}
+	}
+
+	// Delete markers are kept when only some versions are being deleted, or
+	// when they can not match a filter.
+	keepDeleteMarkers := *flags.skipDeleteMarkers || *flags.tagForDeletion || *flags.storageClass != "" || filterTag != nil || *flags.currentOnly
+
+	// The age and size filters apply to delete markers as well, so a recent
+	// delete marker is not removed along with the old versions it hides.
+	// Delete markers have no size so only pass a size filter with no
+	// minimum.
+	deleteMarkerFilters := []versionFilter{}
+	if *flags.minSize != "" || *flags.maxSize != "" {
+		var minSize int64
+		var err error
+		if *flags.minSize != "" {
+			if minSize, err = parseSize(*flags.minSize); err != nil {
+				fmt.Printf("Invalid -min-size: %s.\n", err)
+				return 1
+			}
+		}
+		// maxSize stays nil without -max-size, -max-size 0 only matches
+		// empty versions.
+		var maxSize *int64
+		if *flags.maxSize != "" {
+			size, err := parseSize(*flags.maxSize)
+			if err != nil {
+				fmt.Printf("Invalid -max-size: %s.\n", err)
+				return 1
+			}
+			if size < minSize {
+				fmt.Println("-max-size can not be smaller than -min-size.")
+				return 1
+			}
+			maxSize = &size
+		}
+		versionFilters = append(versionFilters, newSizeFilter(minSize, maxSize))
+		deleteMarkerFilters = append(deleteMarkerFilters, newSizeFilter(minSize, maxSize))
+	}
+	now := time.Now()
+	for _, age := range []struct {
+		name  string
+		value string
+		newer bool
+	}{
+		{"older-than", *flags.olderThan, false},
+		{"newer-than", *flags.newerThan, true},
+	} {
+		if age.value == "" {
+			continue
+		}
+		cutoff, err := parseCutoff(age.value, now)
+		if err != nil {
+			fmt.Printf("Invalid -%s: %s.\n", age.name, err)
+			return 1
+		}
+		versionFilters = append(versionFilters, newAgeFilter(cutoff, age.newer))
+		deleteMarkerFilters = append(deleteMarkerFilters, newAgeFilter(cutoff, age.newer))
+	}
+
+	if *flags.deleteMarkersOnly {
+		versionFilters = append(versionFilters, noVersions)
+	}
+	if *flags.noncurrentOnly {
+		versionFilters = append(versionFilters, newLatestFilter(false))
+		deleteMarkerFilters = append(deleteMarkerFilters, newLatestFilter(false))
+	}
+	if *flags.currentOnly {
+		versionFilters = append(versionFilters, newLatestFilter(true))
+	}
+
+	if *flags.reviewThenDelete && !*flags.yes && *flags.bucketConcurrency > 1 {
+		fmt.Println("-review-then-delete needs -yes when -bucket-concurrency is above 1, as the prompts would be mixed together.")
+		return 1
+	}
+
+	if *flags.archiveBucket != "" && contains(buckets, *flags.archiveBucket) {
+		fmt.Println("-archive-bucket can not be one of the buckets being emptied.")
+		return 1
+	}
+
+	if *flags.notifyTopic != "" {
+		if _, err := parseTopicARN(*flags.notifyTopic); err != nil {
+			fmt.Printf("Invalid -notify-sns-topic-arn: %s.\n", err)
+			return 1
+		}
+	}
+
+	bucketRegions := map[string]string{}
+	if *flags.regionPerBucketFile != "" {
+		var err error
+		bucketRegions, err = readRegionFile(*flags.regionPerBucketFile)
+		if err != nil {
+			fmt.Printf("There was an error reading the region file. Error: %s\n", err)
+			return 1
+		}
+	}
+	if *flags.regionPerBucket != "" {
+		regions, err := parseRegionMap(*flags.regionPerBucket)
+		if err != nil {
+			fmt.Printf("Invalid -region-per-bucket: %s.\n", err)
+			return 1
+		}
+		for bucket, region := range regions {
+			bucketRegions[bucket] = region
+		}
+	}
+
+	prefixes := []string{}
+	if *flags.prefix != "" {
+		prefixes = append(prefixes, *flags.prefix)
+	}
+	if *flags.prefixFile != "" {
+		filePrefixes, err := readPrefixFile(*flags.prefixFile)
+		if err != nil {
+			fmt.Printf("There was an error reading the prefix file. Error: %s\n", err)
+			return 1
+		}
+		if len(filePrefixes) == 0 {
+			fmt.Printf("No prefixes were found in %s.\n", *flags.prefixFile)
+			return 1
+		}
+		prefixes = collapsePrefixes(append(prefixes, filePrefixes...))
+	}
+
+	staticCreds := staticCredentials{
+		AccessKeyId:     *flags.accessKeyId,
+		SecretAccessKey: *flags.secretAccessKey,
+		SessionToken:    *flags.sessionToken,
+	}
+	if !contains(credentialSources, *flags.credentialSource) {
+		fmt.Printf("%s is not a valid credential source, use one of %s.\n", *flags.credentialSource, strings.Join(credentialSources, ","))
+		return 1
+	}
+	if staticCreds.isSet() {
+		if err := staticCreds.validate(); err != nil {
+			fmt.Printf("Invalid credentials flags: %s.\n", err)
+			return 1
+		}
+		if *flags.secretAccessKey != "" {
+			fmt.Fprintln(os.Stderr, "WARNING: passing secrets on the command line is insecure. Prefer -credentials-file or the AWS_* environment variables.")
+		}
+	}
+	// Static credentials and the other named sources never read the web
+	// identity variables, so they are only checked when they would be used.
+	if !staticCreds.isSet() && *flags.credentialsFile == "" && (*flags.credentialSource == "default" || *flags.credentialSource == "web-identity") {
+		if err := checkWebIdentityEnv(); err != nil {
+			fmt.Printf("Web identity credentials are not usable: %s.\n", err)
+			return exitAuthFailed
+		}
+	}
+	if *flags.credentialsFile != "" {
+		var err error
+		staticCreds, err = readCredentialsFile(*flags.credentialsFile)
+		if err != nil {
+			fmt.Printf("There was an error reading the credentials file. Error: %s\n", err)
+			return 1
+		}
+	}
+
+	var logger *eventLogger
+	if *flags.logFormat != "" && !contains(logFormats, *flags.logFormat) {
+		fmt.Printf("-log-format must be one of %s.\n", strings.Join(logFormats, ","))
+		return 1
+	}
+	if *flags.logFormat != "" {
+		level, err := parseLogLevel(*flags.logLevel)
+		if err != nil {
+			fmt.Printf("Invalid -log-level: %s.\n", err)
+			return 1
+		}
+		logger = &eventLogger{}
+		logger.addSink(os.Stderr, *flags.logFormat, level)
+	}
+	if *flags.logFile != "" {
+		maxSize, err := parseSize(*flags.logFileMaxSize)
+		if err != nil {
+			fmt.Printf("Invalid -log-file-max-size: %s.\n", err)
+			return 1
+		}
+		logFile, err := openRotatingFile(*flags.logFile, maxSize, *flags.logFileBackups)
+		if err != nil {
+			fmt.Printf("Could not open the log file. Error: %s\n", err)
+			return 1
+		}
+		defer logFile.Close()
+		if logger == nil {
+			logger = &eventLogger{}
+		}
+		logger.addSink(logFile, *flags.logFormat, levelDebug)
+	}
+	logger.info("run started", field("version", version), field("correlationId", correlationID), field("buckets", strings.Join(buckets, ",")), field("dryRun", *flags.dryRun || *flags.dryRunSummary))
+
+	listOpts := listOptions{
+		prefixes:            prefixes,
+		prefixConcurrency:   *flags.prefixConcurrency,
+		shards:              *flags.listShards,
+		discoverPrefixes:    *flags.discoverPrefixes,
+		filters:             filters,
+		versionFilters:      versionFilters,
+		deleteMarkerFilters: deleteMarkerFilters,
+		withMetadata:        *flags.checksumVerify,
+	}
+
+	// With -stream-results stdout only carries the batch records, so the
+	// rest of the output moves to stderr. notices are the messages that
+	// always go to stderr.
+	var console io.Writer = os.Stdout
+	var notices io.Writer = os.Stderr
+	if *flags.streamResults {
+		console = os.Stderr
+	}
+	// JSON logs are read by machines, so the messages are logged as well
+	// rather than printed next to them. Output that was asked for, like a
+	// listing or -stats, is still printed, to output.
+	output := console
+	jsonLogs := *flags.logFormat == "json"
+	if jsonLogs {
+		console = logWriter{log: logger, level: levelInfo}
+		notices = console
+	}
+	// info is for output that only says how things are going, -quiet drops
+	// it.
+	info := console
+	if *flags.quiet {
+		info = io.Discard
+	}
+
+	// The status signal is handled for the whole run, so it never ends the
+	// process while listing, with -list-only or between buckets.
+	statuses := &statusBoard{}
+	defer watchStatusSignal(notices, statuses)()
+
+	var ticker *requestTicker
+	if *flags.maxRequestsPerSecond > 0 {
+		ticker = newRequestTicker(*flags.maxRequestsPerSecond)
+	}
+	awsOptions := sessionOptions{
+		profile:          *flags.profile,
+		staticCreds:      staticCreds,
+		endpoint:         *flags.endpointURL,
+		forcePathStyle:   *flags.forcePathStyle,
+		verbosity:        flags.verbose,
+		credentialSource: *flags.credentialSource,
+		correlationID:    correlationID,
+		adaptiveBackoff:  !*flags.noAdaptiveBackoff,
+		retry: retryPolicy{
+			maxRetries: *flags.maxRetries,
+			baseDelay:  *flags.retryBaseDelay,
+			maxDelay:   *flags.retryMaxDelay,
+		},
+		requestTicker: ticker,
+	}
+
+	r := &runner{
+		runFlags:           flags,
+		listOpts:           listOpts,
+		prefixes:           prefixes,
+		keepDeleteMarkers:  keepDeleteMarkers,
+		filterTag:          filterTag,
+		deleteTag:          deletionTag,
+		lineTemplate:       lineTemplate,
+		sseKey:             sseKey,
+		streamListing:      streamListing,
+		streaming:          streaming,
+		awsOptions:         awsOptions,
+		bucketRegions:      bucketRegions,
+		logger:             logger,
+		jsonLogs:           jsonLogs,
+		console:            console,
+		notices:            notices,
+		info:               info,
+		out:                output,
+		statuses:           statuses,
+		deleteRequestSlots: make(chan struct{}, *flags.maxDeleteRequests),
+	}
+	return r.emptyBuckets(buckets, reports)
 }
 
-func setupAwsSession(profile string) (*session.Session, error) {
+// sessionOptions changes how setupAwsSession builds the session.
+type sessionOptions struct {
+	profile     string
+	staticCreds staticCredentials
+	// endpoint is used for S3 compatible stores.
+	endpoint string
+	// verbosity turns on SDK logging at the higher -verbose levels.
+	verbosity verbosityFlag
+	// forcePathStyle puts the bucket in the path of the URL rather than the
+	// host name, which most S3 compatible stores need.
+	forcePathStyle bool
+	// credentialSource picks a single credential provider instead of the
+	// default chain. See credentialSources.
+	credentialSource string
+	// correlationID is added to every request, see addCorrelationID.
+	correlationID string
+	// adaptiveBackoff slows every request down while S3 is throttling, see
+	// addAdaptiveBackoff.
+	adaptiveBackoff bool
+	retry           retryPolicy
+	// requestTicker, when set, paces every request.
+	requestTicker *requestTicker
+}
+
+// s3EndpointResolver sends S3 requests to endpoint. Every other service,
+// like CloudWatch for -emit-metrics and SNS for -notify-sns-topic-arn, stays
+// on its usual AWS endpoint, which Config.Endpoint would not allow as it
+// applies to every client made from the session.
+func s3EndpointResolver(endpoint string) endpoints.ResolverFunc {
+	return func(service, region string, opts ...func(*endpoints.Options)) (endpoints.ResolvedEndpoint, error) {
+		if service != endpoints.S3ServiceID {
+			return endpoints.DefaultResolver().EndpointFor(service, region, opts...)
+		}
+		options := endpoints.Options{}
+		options.Set(opts...)
+		return endpoints.ResolvedEndpoint{
+			URL:           endpoints.AddScheme(endpoint, options.DisableSSL),
+			SigningRegion: region,
+		}, nil
+	}
+}
+
+// setupAwsSession creates the session used for all requests. Static
+// credentials, when given, take precedence over anything the profile would
+// resolve to. The profile is still loaded for its other settings like region.
+func setupAwsSession(opts sessionOptions) (*session.Session, error) {
+	config := aws.Config{}
+	if opts.endpoint != "" {
+		config.EndpointResolver = s3EndpointResolver(opts.endpoint)
+	}
+	if opts.forcePathStyle {
+		config.S3ForcePathStyle = aws.Bool(true)
+	}
+	opts.verbosity.applyToConfig(&config)
+	opts.retry.applyToConfig(&config)
+	if opts.staticCreds.isSet() {
+		config.Credentials = opts.staticCreds.toCredentials()
+	}
+
+	profile := opts.profile
+	if opts.credentialSource == "profile" && profile == "" {
+		profile = os.Getenv("AWS_PROFILE")
+		if profile == "" {
+			profile = "default"
+		}
+	}
+
+	var awsSession *session.Session
+	var err error
 	if profile != "" {
-		return session.NewSessionWithOptions(session.Options{
+		awsSession, err = session.NewSessionWithOptions(session.Options{
+			Config:            config,
 			Profile:           profile,
 			SharedConfigState: session.SharedConfigEnable,
 		})
+	} else {
+		awsSession, err = session.NewSession(&config)
 	}
+	if err != nil {
+		return nil, err
+	}
+
+	creds, err := credentialsFromSource(awsSession, opts.credentialSource)
+	if err != nil {
+		return nil, err
+	}
+	if creds != nil {
+		awsSession.Config.Credentials = creds
+	}
+	if opts.correlationID != "" {
+		addCorrelationID(awsSession, opts.correlationID)
+	}
+	if opts.adaptiveBackoff {
+		addAdaptiveBackoff(awsSession)
+	}
+	if opts.requestTicker != nil {
+		opts.requestTicker.addTo(awsSession)
+	}
+	return awsSession, nil
+}
+
+// listOptions changes what listObjects returns.
+type listOptions struct {
+	// prefixes limits the listing to keys under these prefixes. All keys are
+	// listed when it is empty.
+	prefixes []string
+	// prefixConcurrency is how many prefixes are listed at the same time.
+	prefixConcurrency int
+	// shards splits the key space into this many ranges that are listed at
+	// the same time. It is not used with prefixes.
+	shards int
+	// discoverPrefixes finds the prefixes one level down with a delimiter
+	// listing, then lists them prefixConcurrency at a time. It works under
+	// at most one prefix.
+	discoverPrefixes bool
+	filters          []keyFilter
+	// versionFilters are applied to object versions after the key filters.
+	// Delete markers are not affected by them.
+	versionFilters []versionFilter
+	// deleteMarkerFilters are applied to delete markers after the key
+	// filters, each marker is passed as an object with its key, version id,
+	// LastModified and IsLatest.
+	deleteMarkerFilters []versionFilter
+	// withMetadata keeps the ETag and Owner of each version.
+	withMetadata bool
+	// observer is told about each page listed, it may be nil.
+	observer Observer
+}
+
+func listObjects(awsSession *session.Session, bucket string, opts listOptions) (*objectList, error) {
+	s3Handler := newListingClient(awsSession)
+
+	var returnValue *objectList
+	var err error
+	switch {
+	case opts.discoverPrefixes:
+		prefix := ""
+		if len(opts.prefixes) == 1 {
+			prefix = opts.prefixes[0]
+		}
+		var prefixes []string
+		var top *objectList
+		prefixes, top, err = discoverPrefixes(s3Handler, bucket, prefix, opts)
+		if err != nil {
+			break
+		}
+		returnValue, _, err = listRanges(s3Handler, bucket, prefixRanges(prefixes), opts.prefixConcurrency, opts)
+		if err == nil {
+			returnValue.merge(top)
+		}
+	case len(opts.prefixes) > 0:
+		var counts map[keyRange]int64
+		returnValue, counts, err = listRanges(s3Handler, bucket, prefixRanges(opts.prefixes), opts.prefixConcurrency, opts)
+		if err == nil {
+			returnValue.PrefixCounts = make(map[string]int64, len(counts))
+			for r, count := range counts {
+				returnValue.PrefixCounts[r.prefix] = count
+			}
+		}
+	case opts.shards > 1:
+		shards := shardRanges(opts.shards)
+		returnValue, _, err = listRanges(s3Handler, bucket, shards, len(shards), opts)
+	default:
+		returnValue, err = listRange(s3Handler, bucket, keyRange{}, opts)
+	}
+	if err != nil {
+		return newObjectList(), &ListError{Bucket: bucket, Err: err}
+	}
+
+	returnValue.Bucket = bucket
+	returnValue.GeneratedAt = aws.Time(time.Now().UTC())
+
+	if returnValue.ObjectCount == 0 {
+		return newObjectList(), emptyListingError(s3Handler, bucket, returnValue.scanned, len(opts.prefixes) > 0)
+	}
+	return returnValue, nil
+}
+
+// emptyListingError works out why nothing was found. If anything was seen
+// before filtering, or the bucket has keys outside the prefixes, then the
+// filters did not match. Otherwise the bucket really is empty.
+func emptyListingError(s3Handler *s3.S3, bucket string, scanned int64, usedPrefixes bool) error {
+	if scanned > 0 {
+		return ErrNoMatchingObjects
+	}
+	if !usedPrefixes {
+		return ErrEmptyBucket
+	}
+
+	out, err := s3Handler.ListObjectVersions(&s3.ListObjectVersionsInput{
+		Bucket:  aws.String(bucket),
+		MaxKeys: aws.Int64(1),
+	})
+	if err != nil {
+		return &ListError{Bucket: bucket, Err: err}
+	}
+	if len(out.Versions) > 0 || len(out.DeleteMarkers) > 0 {
+		return ErrNoMatchingObjects
+	}
+	return ErrEmptyBucket
+}
+
+// keyRange bounds a listing. Only keys starting with prefix, after the key
+// after and up to and including the key upTo are listed. Empty values mean no
+// bound.
+type keyRange struct {
+	prefix string
+	after  string
+	upTo   string
+}
+
+func (r keyRange) String() string {
+	if r.after == "" && r.upTo == "" {
+		return r.prefix
+	}
+	return fmt.Sprintf("%s(%q, %q]", r.prefix, r.after, r.upTo)
+}
 
-	return session.NewSession()
+func (r keyRange) pastEnd(key string) bool {
+	return r.upTo != "" && key > r.upTo
 }
 
-func listObjects(awsSession *session.Session, bucket string) (*objectList, error) {
-	s3Handler := s3.New(awsSession)
+// listRanges lists the ranges with up to concurrency of them at a time and
+// merges the results. The number of objects found in each range is also
+// returned.
+func listRanges(s3Handler *s3.S3, bucket string, ranges []keyRange, concurrency int, opts listOptions) (*objectList, map[keyRange]int64, error) {
+	type rangeResult struct {
+		r    keyRange
+		list *objectList
+		err  error
+	}
+
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	work := make(chan keyRange)
+	results := make(chan rangeResult)
+	wg := sync.WaitGroup{}
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for r := range work {
+				list, err := listRange(s3Handler, bucket, r, opts)
+				results <- rangeResult{r: r, list: list, err: err}
+			}
+		}()
+	}
+	go func() {
+		for _, r := range ranges {
+			work <- r
+		}
+		close(work)
+		wg.Wait()
+		close(results)
+	}()
+
+	// Results are merged here on a single goroutine so the list needs no locking.
+	returnValue := newObjectList()
+	counts := make(map[keyRange]int64, len(ranges))
+	errs := []string{}
+	for result := range results {
+		if result.err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %s", result.r, result.err))
+			continue
+		}
+		counts[result.r] = result.list.ObjectCount
+		returnValue.merge(result.list)
+	}
+
+	if len(errs) > 0 {
+		sort.Strings(errs)
+		return newObjectList(), nil, fmt.Errorf("failed to list %d of %d ranges: %s", len(errs), len(ranges), strings.Join(errs, "; "))
+	}
+	return returnValue, counts, nil
+}
+
+// addPage adds the versions and delete markers in a listing page that are in
+// the range and pass the filters.
+func (objList *objectList) addPage(page *s3.ListObjectVersionsOutput, r keyRange, opts listOptions) {
+	for _, v := range page.Versions {
+		key := aws.StringValue(v.Key)
+		if r.pastEnd(key) {
+			continue
+		}
+		objList.scanned++
+		if !matchesAll(opts.filters, key) {
+			continue
+		}
+		obj := objectFromVersion(v)
+		if !versionMatchesAll(opts.versionFilters, obj) {
+			continue
+		}
+		if opts.withMetadata {
+			obj.ETag = strings.Trim(aws.StringValue(v.ETag), `"`)
+			if v.Owner != nil {
+				obj.Owner = aws.StringValue(v.Owner.ID)
+			}
+		}
+		objList.add(obj)
+	}
+	deleteMarkers := make([]*s3.DeleteMarkerEntry, 0, len(page.DeleteMarkers))
+	for _, dm := range page.DeleteMarkers {
+		key := aws.StringValue(dm.Key)
+		if r.pastEnd(key) {
+			continue
+		}
+		objList.scanned++
+		if matchesAll(opts.filters, key) && deleteMarkerMatchesAll(opts.deleteMarkerFilters, dm) {
+			deleteMarkers = append(deleteMarkers, dm)
+		}
+	}
+	objList.appendDeleteMarkers(deleteMarkers)
+}
 
+// listRange lists every version and delete marker in the range that passes
+// the filters.
+func listRange(s3Handler *s3.S3, bucket string, r keyRange, opts listOptions) (*objectList, error) {
 	wg := sync.WaitGroup{}
 	objectHopper := make(chan s3.ListObjectVersionsOutput, 1)
 	returnValue := newObjectList()
@@ -168,18 +1026,27 @@ func listObjects(awsSession *session.Session, bucket string) (*objectList, error
 	go func(hopper chan s3.ListObjectVersionsOutput) {
 		defer wg.Done()
 		for page := range hopper {
-			for _, obj := range page.Versions {
-				returnValue.add(aws.StringValue(obj.Key), aws.StringValue(obj.VersionId))
-			}
-			returnValue.appendDeleteMarkers(page.DeleteMarkers)
+			returnValue.addPage(&page, r, opts)
 		}
 	}(objectHopper)
 
-	err := s3Handler.ListObjectVersionsPages(&s3.ListObjectVersionsInput{
+	input := &s3.ListObjectVersionsInput{
 		Bucket: aws.String(bucket),
-	}, func(page *s3.ListObjectVersionsOutput, lastPage bool) bool {
+	}
+	if r.prefix != "" {
+		input.Prefix = aws.String(r.prefix)
+	}
+	if r.after != "" {
+		input.KeyMarker = aws.String(r.after)
+	}
+	err := s3Handler.ListObjectVersionsPages(input, func(page *s3.ListObjectVersionsOutput, lastPage bool) bool {
+		if opts.observer != nil {
+			opts.observer.OnListPage(page)
+		}
 		objectHopper <- *page
-		return true
+		// Keys come back in order, so once a page goes past the end of the
+		// range there is nothing more to find.
+		return !pagePastEnd(page, r)
 	})
 
 	close(objectHopper)
@@ -188,27 +1055,54 @@ func listObjects(awsSession *session.Session, bucket string) (*objectList, error
 	if err != nil {
 		return newObjectList(), err
 	}
-
-	if returnValue.ObjectCount == 0 {
-		return newObjectList(), fmt.Errorf("no objects found")
-	}
 	return returnValue, nil
 }
 
-func deleteObjects(awsSession *session.Session, bucketName string, objects objectList) ([]string, error) {
-	s3Handler := s3.New(awsSession)
+func pagePastEnd(page *s3.ListObjectVersionsOutput, r keyRange) bool {
+	if n := len(page.Versions); n > 0 && r.pastEnd(aws.StringValue(page.Versions[n-1].Key)) {
+		return true
+	}
+	if n := len(page.DeleteMarkers); n > 0 && r.pastEnd(aws.StringValue(page.DeleteMarkers[n-1].Key)) {
+		return true
+	}
+	return false
+}
 
+// batchDeletes splits the versions and delete markers in the list into the
+// DeleteObjects batches that are sent, dropping repeats. Directory markers
+// get batches of their own, dirPacks, so they can be sent once everything
+// else has gone. sizes holds the size of each version by versionKey.
+func batchDeletes(objects *objectList, opts deleteOptions) (objectPacks, dirPacks []*s3.Delete, sizes map[string]int64) {
 	s3ObjectsRaw := []*s3.ObjectIdentifier{}
 	s3DirsRaw := []*s3.ObjectIdentifier{}
 
+	// Largest first replaces the directory ordering, directory markers are
+	// sent in size order with everything else.
+	versions := objects.Objects
+	if opts.largestFirst {
+		versions = make([]object, len(objects.Objects))
+		copy(versions, objects.Objects)
+		sort.SliceStable(versions, func(i, j int) bool {
+			return versions[i].Size > versions[j].Size
+		})
+	}
+
+	// sizes is only needed when versions are really removed.
+	sizes = map[string]int64{}
 	dirMatcher := regexp.MustCompile("/$")
-	for _, obj := range objects.Objects {
+	for _, obj := range versions {
+		if obj.Size > 0 && !opts.markDeleted && opts.tag == nil {
+			sizes[versionKey(&obj.Key, &obj.VersionId)] = obj.Size
+		}
 		currentObject := &s3.ObjectIdentifier{
 			Key:       aws.String(obj.Key),
 			VersionId: aws.String(obj.VersionId),
 		}
+		if opts.markDeleted {
+			currentObject.VersionId = nil
+		}
 
-		if dirMatcher.MatchString(obj.Key) {
+		if dirMatcher.MatchString(obj.Key) && !opts.largestFirst {
 			s3DirsRaw = append(s3DirsRaw, currentObject)
 		} else {
 			s3ObjectsRaw = append(s3ObjectsRaw, currentObject)
@@ -223,6 +1117,9 @@ func deleteObjects(awsSession *session.Session, bucketName string, objects objec
 		s3ObjectsRaw = append(s3ObjectsRaw, currentObject)
 	}
 
+	s3ObjectsRaw = dedupeIdentifiers(s3ObjectsRaw)
+	s3DirsRaw = dedupeIdentifiers(s3DirsRaw)
+
 	// Sort the objects so that we can delete the deepest directories first
 	sort.SliceStable(s3DirsRaw, func(i, j int) bool {
 		a := strings.Count(aws.StringValue(s3DirsRaw[i].Key), "/")
@@ -230,70 +1127,381 @@ func deleteObjects(awsSession *session.Session, bucketName string, objects objec
 		return a < b
 	})
 
-	deletePacks := []*s3.Delete{}
-	deletePacks = append(deletePacks, &s3.Delete{})
+	for _, chunk := range chunkIdentifiers(s3ObjectsRaw, opts.batchSize, opts.maxBatchBytes) {
+		objectPacks = append(objectPacks, &s3.Delete{Objects: chunk})
+	}
+	for _, chunk := range chunkIdentifiers(s3DirsRaw, opts.batchSize, opts.maxBatchBytes) {
+		dirPacks = append(dirPacks, &s3.Delete{Objects: chunk})
+	}
+	return objectPacks, dirPacks, sizes
+}
+
+// chunkIdentifiers splits ids into batches of at most size, keeping their
+// order. A batch is also ended early if its estimated request body would go
+// over maxBytes, a maxBytes of 0 turns that off.
+func chunkIdentifiers(ids []*s3.ObjectIdentifier, size, maxBytes int) [][]*s3.ObjectIdentifier {
+	chunks := [][]*s3.ObjectIdentifier{}
+	start, chunkBytes := 0, 0
+	for i, id := range ids {
+		idBytes := identifierSize(id)
+		full := i-start == size || (maxBytes > 0 && i > start && chunkBytes+idBytes > maxBytes)
+		if full {
+			chunks = append(chunks, ids[start:i:i])
+			start, chunkBytes = i, 0
+		}
+		chunkBytes += idBytes
+	}
+	if start < len(ids) {
+		chunks = append(chunks, ids[start:])
+	}
+	return chunks
+}
 
-	index := 0
+// identifierSize estimates how many bytes id adds to a DeleteObjects request.
+// Keys are XML escaped, which can only make them longer, so escaping is
+// allowed for by counting the characters that grow.
+func identifierSize(id *s3.ObjectIdentifier) int {
+	key := aws.StringValue(id.Key)
+	escaped := strings.Count(key, "&")*4 + strings.Count(key, "<")*3 + strings.Count(key, ">")*3 +
+		strings.Count(key, "\"")*5 + strings.Count(key, "'")*5
+	return identifierOverhead + len(key) + escaped + len(aws.StringValue(id.VersionId))
+}
 
-	if len(s3ObjectsRaw) > 0 {
-		if len(s3ObjectsRaw) <= 1000 {
-			deletePacks = append(deletePacks, &s3.Delete{Objects: s3ObjectsRaw})
-		} else {
-			counter := 1
-			max := 1000
-			for _, o := range s3ObjectsRaw {
-				if counter <= max-1 {
-					counter++
-					deletePacks[index].Objects = append(deletePacks[index].Objects, o)
-				} else {
-					deletePacks = append(deletePacks, &s3.Delete{})
-					index++
-					counter = 1
-					deletePacks[index].Objects = append(deletePacks[index].Objects, o)
-				}
-			}
+// dedupeIdentifiers drops repeated Key and VersionId pairs, keeping the first
+// occurrence. Duplicates can come from overlapping filters or from a version
+// and a delete marker sharing an id.
+func dedupeIdentifiers(ids []*s3.ObjectIdentifier) []*s3.ObjectIdentifier {
+	seen := make(map[string]struct{}, len(ids))
+	unique := make([]*s3.ObjectIdentifier, 0, len(ids))
+	for _, id := range ids {
+		k := aws.StringValue(id.Key) + "\x00" + aws.StringValue(id.VersionId)
+		if _, ok := seen[k]; ok {
+			continue
 		}
+		seen[k] = struct{}{}
+		unique = append(unique, id)
 	}
+	return unique
+}
 
-	if len(s3DirsRaw) > 0 {
-		if len(s3DirsRaw) <= 1000 {
-			deletePacks = append(deletePacks, &s3.Delete{Objects: s3DirsRaw})
-		} else {
-			counter := 0
-			max := 1000
-			for _, o := range s3DirsRaw {
-				if counter <= max {
-					counter++
-					deletePacks[index].Objects = append(deletePacks[index].Objects, o)
-				} else {
-					deletePacks = append(deletePacks, &s3.Delete{})
-					index++
-					counter = 1
-					deletePacks[index].Objects = append(deletePacks[index].Objects, o)
-				}
-			}
+// verifyEmpty checks that no versions or delete markers remain in the bucket,
+// or under each of the prefixes if any are given.
+func verifyEmpty(awsSession *session.Session, bucket string, prefixes []string) (bool, error) {
+	s3Handler := newListingClient(awsSession)
+	if len(prefixes) == 0 {
+		prefixes = []string{""}
+	}
+	for _, prefix := range prefixes {
+		input := &s3.ListObjectVersionsInput{
+			Bucket:  aws.String(bucket),
+			MaxKeys: aws.Int64(1),
+		}
+		if prefix != "" {
+			input.Prefix = aws.String(prefix)
+		}
+		out, err := s3Handler.ListObjectVersions(input)
+		if err != nil {
+			return false, err
+		}
+		if len(out.Versions) > 0 || len(out.DeleteMarkers) > 0 {
+			return false, nil
 		}
 	}
+	return true, nil
+}
 
-	for _, deletePack := range deletePacks {
-		objectsToDelete := s3.DeleteObjectsInput{
-			Bucket: aws.String(bucketName),
-			Delete: deletePack,
+// printDeleteResult shows what went wrong while deleting, if anything, and
+// records it in the report.
+func printDeleteResult(w io.Writer, result *deleteResult, err error, report *runReport) {
+	report.addDeleteResult(result)
+	if result.SingleDelete {
+		fmt.Fprintf(w, "Objects were deleted one request at a time with DeleteObject. This takes %d requests rather than %d batches and is much slower.\n", result.ObjectsDeleted+result.DeleteMarkersDeleted+result.DirectoriesDeleted+result.DeleteMarkersCreated+result.Failures, result.Batches)
+	}
+	var deleteErr *DeleteError
+	if errors.As(err, &deleteErr) && deleteErr.Err != nil {
+		report.addError(err)
+		fmt.Fprintf(w, "There was an error deleting objects. Error: %s.\n", deleteErr.Err)
+		printRegionHint(w, deleteErr.Err)
+	}
+	if len(result.Errors) > 0 {
+		fmt.Fprintln(w, "Raw Request Errors:")
+		for _, e := range result.Errors {
+			fmt.Fprintln(w, e)
+		}
+		if result.Failures > int64(len(result.Errors)) {
+			fmt.Fprintf(w, "%d errors shown, %d total failures. Use -max-error-details to see more.\n", len(result.Errors), result.Failures)
 		}
-		if len(deletePack.Objects) == 0 {
+	}
+}
+
+// printRegionHint says which region to use if err came from using the wrong
+// one.
+func printRegionHint(w io.Writer, err error) {
+	if hint := wrongRegionHint(err); hint != "" {
+		fmt.Fprintf(w, "Hint: %s.\n", hint)
+	}
+}
+
+// deleteResult tallies what a deleteObjects call achieved. record is safe to
+// call from many goroutines at once.
+type deleteResult struct {
+	lock sync.Mutex
+
+	ObjectsDeleted       int64
+	DeleteMarkersDeleted int64
+	DirectoriesDeleted   int64
+	// DeleteMarkersCreated counts the objects hidden by a new delete marker
+	// with -current-only, their versions are still there.
+	DeleteMarkersCreated int64
+	// BytesDeleted is the size of the versions that were removed. Delete
+	// markers and versions hidden by -current-only free nothing.
+	BytesDeleted  int64
+	Batches       int
+	BatchesFailed int
+	// Errors holds the details of at most maxErrors failures so a bucket
+	// where everything fails can not run out of memory. Failures counts
+	// all of them.
+	Errors    []string
+	Failures  int64
+	maxErrors int
+	// SingleDelete is set if objects were deleted one request at a time.
+	SingleDelete bool
+	// markDeleted is set when the deletes add delete markers instead of
+	// removing versions.
+	markDeleted bool
+}
+
+// newDeleteResult starts a result that keeps the details of up to maxErrors
+// failures, 0 keeps them all.
+func newDeleteResult(maxErrors int) *deleteResult {
+	return &deleteResult{Errors: make([]string, 0), maxErrors: maxErrors}
+}
+
+// record counts the outcome of a batch. sizes holds the size of each version
+// in the batch by versionKey.
+func (r *deleteResult) record(results []Result, sizes map[string]int64) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	for _, res := range results {
+		if res.Err != nil {
+			r.Failures++
+			if r.maxErrors == 0 || len(r.Errors) < r.maxErrors {
+				r.Errors = append(r.Errors, res.Err.String())
+			}
 			continue
 		}
-		fmt.Printf("Attemting to delete %d objects\n", len(deletePack.Objects))
-		out, err := s3Handler.DeleteObjects(&objectsToDelete)
+		r.BytesDeleted += sizes[versionKey(&res.Key, &res.VersionId)]
+		switch {
+		case r.markDeleted:
+			// S3 says DeleteMarker for the marker it just added, nothing
+			// was removed.
+			r.DeleteMarkersCreated++
+		case res.DeleteMarker:
+			r.DeleteMarkersDeleted++
+		case strings.HasSuffix(res.Key, "/"):
+			r.DirectoriesDeleted++
+		default:
+			r.ObjectsDeleted++
+		}
+	}
+}
+
+// deleteOptions changes how deleteObjects behaves.
+type deleteOptions struct {
+	// batchSize is the most objects sent in one DeleteObjects request.
+	batchSize int
+	// maxBatchBytes ends a batch early when its keys would make the request
+	// larger than this. 0 means no limit.
+	maxBatchBytes int
+	// observer is told about each batch, it may be nil.
+	observer Observer
+	// simulate swaps the delete requests for a stand in that waits for
+	// simulateLatency and then reports success.
+	simulate        bool
+	simulateLatency time.Duration
+	// tag, when set, is added to each object instead of deleting it.
+	tag *s3.Tag
+	// maxErrorDetails caps how many failures are kept in the result, 0
+	// keeps them all.
+	maxErrorDetails int
+	// largestFirst sends the biggest versions first instead of holding the
+	// directory markers back to the end.
+	largestFirst bool
+	// singleDelete uses a DeleteObject call per object instead of
+	// DeleteObjects. It is turned on by itself if the endpoint does not
+	// support DeleteObjects.
+	singleDelete bool
+	// archiveBucket, when set, has every version copied into it before it
+	// is deleted.
+	archiveBucket string
+	// requestSlots, when set, holds a slot for each batch being sent. It is
+	// shared between buckets to cap the requests in flight across all of
+	// them.
+	requestSlots chan struct{}
+	// markDeleted sends the deletes without version ids, so S3 adds a
+	// delete marker to each key instead of removing the version.
+	markDeleted bool
+	// concurrency is how many batches are sent at the same time.
+	concurrency int
+	// quietDelete has S3 only send back the failures of each batch.
+	quietDelete bool
+	// info gets the notices that -quiet hides. Stderr is used when it is nil.
+	info io.Writer
+}
+
+// notices returns where to write the notices that -quiet hides.
+func (opts deleteOptions) notices() io.Writer {
+	if opts.info == nil {
+		return os.Stderr
+	}
+	return opts.info
+}
+
+func deleteObjects(awsSession *session.Session, bucketName string, objects *objectList, opts deleteOptions) (*deleteResult, error) {
+	result := newDeleteResult(opts.maxErrorDetails)
+	err := deleteObjectsInto(awsSession, bucketName, objects, opts, result)
+	opts.completed(result)
+	return result, err
+}
+
+// completed tells the observer, if there is one, that deleting has finished.
+func (opts deleteOptions) completed(result *deleteResult) {
+	if opts.observer != nil {
+		opts.observer.OnComplete(result)
+	}
+}
+
+// deleteObjectsInto deletes the objects and adds what happened to result.
+// The batches are numbered on from the ones already in result, so a run made
+// of several calls is reported as one. The observer is not told when it
+// finishes, that is up to the caller.
+func deleteObjectsInto(awsSession *session.Session, bucketName string, objects *objectList, opts deleteOptions, result *deleteResult) error {
+	// Once an earlier call fell back to one object at a time there is no
+	// point trying DeleteObjects again.
+	if result.SingleDelete {
+		opts.singleDelete = true
+	}
+	// canFallBack is set while a batch could still be retried one object at a
+	// time.
+	client := s3.New(awsSession)
+	action, canFallBack := newAction(opts, objects.DeleteMarkers)
+
+	objectPacks, dirPacks, sizes := batchDeletes(objects, opts)
+
+	var observer Observer = NopObserver{}
+	if opts.observer != nil {
+		observer = opts.observer
+	}
+
+	result.SingleDelete = opts.singleDelete && !opts.simulate && opts.tag == nil
+	result.markDeleted = opts.markDeleted
+	// lock guards result, action and canFallBack, and keeps the observer
+	// from being called by more than one batch at a time.
+	lock := sync.Mutex{}
+	send := func(deletePack *s3.Delete) error {
+		lock.Lock()
+		result.Batches++
+		event := BatchEvent{Index: result.Batches, Objects: deletePack.Objects}
+		observer.OnBatchStart(event)
+		current := action
+		lock.Unlock()
+
+		// The request id is kept so a slow or failed batch can be matched up
+		// with the S3 server logs.
+		var requestID string
+		recordRequestID := func(r *request.Request) {
+			r.Handlers.Complete.PushBack(func(r *request.Request) {
+				requestID = r.RequestID
+			})
+		}
+		start := time.Now()
+		batch := &Batch{Bucket: bucketName, Objects: deletePack.Objects, Options: []request.Option{recordRequestID}}
+		results, err := current.Apply(aws.BackgroundContext(), client, batch)
+
+		lock.Lock()
+		defer lock.Unlock()
+		if err != nil && canFallBack && hasErrorCode(err, "NotImplemented") {
+			// Some S3 compatible stores have no DeleteObjects, the batch is
+			// sent again one object at a time and so is the rest.
+			fmt.Fprintln(opts.notices(), "DeleteObjects is not supported by the endpoint, deleting one object at a time instead.")
+			action = fallbackAction(opts, objects.DeleteMarkers)
+			result.SingleDelete = true
+			results, err = action.Apply(aws.BackgroundContext(), client, batch)
+		}
+		canFallBack = false
+		event.Duration = time.Since(start)
+		event.RequestId = requestID
+		result.record(results, sizes)
+		errs := resultErrors(results)
+		if err != nil || len(errs) > 0 {
+			result.BatchesFailed++
+		}
 		if err != nil {
-			errs := []string{}
-			for _, e := range out.Errors {
-				errs = append(errs, e.String())
+			observer.OnBatchError(event, err)
+			return err
+		}
+		event.Deleted = len(deletePack.Objects) - len(errs)
+		event.Errors = errs
+		observer.OnBatchDeleted(event)
+		return nil
+	}
+
+	// sendAll sends up to opts.concurrency batches at a time. No more are
+	// started once a request fails. While the endpoint could still turn out
+	// not to support DeleteObjects the first batch is sent on its own.
+	sendAll := func(packs []*s3.Delete) error {
+		if canFallBack && len(packs) > 0 {
+			if err := send(packs[0]); err != nil {
+				return err
 			}
+			packs = packs[1:]
+		}
+
+		concurrency := opts.concurrency
+		if concurrency < 1 {
+			concurrency = 1
+		}
+		work := make(chan *s3.Delete)
+		errs := make(chan error, concurrency)
+		wg := sync.WaitGroup{}
+		for i := 0; i < concurrency; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for deletePack := range work {
+					if err := send(deletePack); err != nil {
+						errs <- err
+						return
+					}
+				}
+			}()
+		}
+		var err error
+	dispatch:
+		for _, deletePack := range packs {
+			select {
+			case err = <-errs:
+				break dispatch
+			case work <- deletePack:
+			}
+		}
+		close(work)
+		wg.Wait()
+		if err == nil && len(errs) > 0 {
+			err = <-errs
+		}
+		return err
+	}
 
-			return errs, err
+	// Directory markers go once every other object is gone.
+	for _, packs := range [][]*s3.Delete{objectPacks, dirPacks} {
+		if err := sendAll(packs); err != nil {
+			return &DeleteError{Bucket: bucketName, Failed: result.Errors, FailureCount: result.Failures, Err: err}
 		}
 	}
 
-	return []string{}, nil
+	if result.Failures > 0 {
+		return &DeleteError{Bucket: bucketName, Failed: result.Errors, FailureCount: result.Failures}
+	}
+	return nil
 }
@@ -0,0 +1,367 @@
+package main
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/cloudwatch"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/sns"
+	"github.com/aws/aws-sdk-go/service/sts"
+)
+
+func identifiers(pairs ...string) []*s3.ObjectIdentifier {
+	ids := []*s3.ObjectIdentifier{}
+	for i := 0; i < len(pairs); i += 2 {
+		ids = append(ids, &s3.ObjectIdentifier{Key: aws.String(pairs[i]), VersionId: aws.String(pairs[i+1])})
+	}
+	return ids
+}
+
+func identifierStrings(ids []*s3.ObjectIdentifier) []string {
+	out := []string{}
+	for _, id := range ids {
+		out = append(out, aws.StringValue(id.Key)+"@"+aws.StringValue(id.VersionId))
+	}
+	return out
+}
+
+func TestDedupeIdentifiers(t *testing.T) {
+	tests := []struct {
+		name string
+		in   []*s3.ObjectIdentifier
+		want []string
+	}{
+		{"empty", identifiers(), []string{}},
+		{"no duplicates", identifiers("a", "1", "a", "2", "b", "1"), []string{"a@1", "a@2", "b@1"}},
+		{"repeated pair", identifiers("a", "1", "b", "1", "a", "1"), []string{"a@1", "b@1"}},
+		{"all the same", identifiers("a", "1", "a", "1", "a", "1"), []string{"a@1"}},
+		{"same version id on other keys", identifiers("a", "null", "b", "null"), []string{"a@null", "b@null"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := identifierStrings(dedupeIdentifiers(tt.in)); !sameStrings(got, tt.want) {
+				t.Fatalf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func manyIdentifiers(n int) []*s3.ObjectIdentifier {
+	ids := make([]*s3.ObjectIdentifier, n)
+	for i := range ids {
+		ids[i] = &s3.ObjectIdentifier{Key: aws.String("key"), VersionId: aws.String("v")}
+	}
+	return ids
+}
+
+func chunkLengths(chunks [][]*s3.ObjectIdentifier) []int {
+	lengths := []int{}
+	for _, c := range chunks {
+		lengths = append(lengths, len(c))
+	}
+	return lengths
+}
+
+func sameInts(a, b []int) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestChunkIdentifiersBatchSize(t *testing.T) {
+	tests := []struct {
+		name  string
+		count int
+		size  int
+		want  []int
+	}{
+		{"partial last batch", 2500, maxAWSBatchSize, []int{1000, 1000, 500}},
+		{"exact batches", 2000, maxAWSBatchSize, []int{1000, 1000}},
+		{"under one batch", 10, maxAWSBatchSize, []int{10}},
+		{"custom batch size", 250, 100, []int{100, 100, 50}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ids := manyIdentifiers(tt.count)
+			chunks := chunkIdentifiers(ids, tt.size, 0)
+			if got := chunkLengths(chunks); !sameInts(got, tt.want) {
+				t.Fatalf("batch sizes are %v, want %v", got, tt.want)
+			}
+			// Every id is sent once and in order.
+			i := 0
+			for _, c := range chunks {
+				for _, id := range c {
+					if id != ids[i] {
+						t.Fatalf("id %d is out of order", i)
+					}
+					i++
+				}
+			}
+		})
+	}
+}
+
+func TestObjectListConcurrentWrites(t *testing.T) {
+	const writers, each = 8, 500
+	list := newObjectList()
+	done := make(chan struct{})
+	for w := 0; w < writers; w++ {
+		go func() {
+			defer func() { done <- struct{}{} }()
+			for i := 0; i < each; i++ {
+				list.add(newObject("key", "v", 1))
+				list.appendDeleteMarkers([]*s3.DeleteMarkerEntry{{Key: aws.String("key"), VersionId: aws.String("dm")}})
+			}
+		}()
+	}
+	for w := 0; w < writers; w++ {
+		<-done
+	}
+
+	if len(list.Objects) != writers*each {
+		t.Errorf("%d objects, want %d", len(list.Objects), writers*each)
+	}
+	if len(list.DeleteMarkers) != writers*each {
+		t.Errorf("%d delete markers, want %d", len(list.DeleteMarkers), writers*each)
+	}
+	if list.ObjectCount != 2*writers*each {
+		t.Errorf("ObjectCount is %d, want %d", list.ObjectCount, 2*writers*each)
+	}
+}
+
+func TestChunkIdentifiersByteCap(t *testing.T) {
+	long := strings.Repeat("k", 1024)
+	ids := make([]*s3.ObjectIdentifier, 10)
+	for i := range ids {
+		ids[i] = &s3.ObjectIdentifier{Key: aws.String(long), VersionId: aws.String("v")}
+	}
+	// Room for three of the long keys, well under the batch size.
+	maxBytes := 3*identifierSize(ids[0]) + 10
+
+	got := chunkLengths(chunkIdentifiers(ids, maxAWSBatchSize, maxBytes))
+	if want := []int{3, 3, 3, 1}; !sameInts(got, want) {
+		t.Fatalf("batch sizes are %v, want %v", got, want)
+	}
+
+	// A single key bigger than the cap is still sent, on its own.
+	got = chunkLengths(chunkIdentifiers(ids[:2], maxAWSBatchSize, 10))
+	if want := []int{1, 1}; !sameInts(got, want) {
+		t.Fatalf("batch sizes are %v, want %v", got, want)
+	}
+}
+
+func TestIdentifierSizeCountsEscaping(t *testing.T) {
+	plain := identifierSize(&s3.ObjectIdentifier{Key: aws.String("a")})
+	escaped := identifierSize(&s3.ObjectIdentifier{Key: aws.String("&")})
+	if escaped != plain+4 {
+		t.Errorf("& counts as %d bytes more than a, want 4", escaped-plain)
+	}
+}
+
+func packKeys(packs []*s3.Delete) [][]string {
+	keys := [][]string{}
+	for _, p := range packs {
+		keys = append(keys, identifierStrings(p.Objects))
+	}
+	return keys
+}
+
+func TestBatchDeletes(t *testing.T) {
+	list := newObjectList()
+	list.add(newObject("a.txt", "1", 10))
+	list.add(newObject("dir/", "1", 0))
+	list.add(newObject("b.txt", "1", 20))
+	list.add(newObject("a.txt", "1", 10))
+	list.add(newObject("dir/sub/", "1", 0))
+	list.appendDeleteMarkers([]*s3.DeleteMarkerEntry{{Key: aws.String("c.txt"), VersionId: aws.String("2")}})
+
+	objectPacks, dirPacks, sizes := batchDeletes(list, deleteOptions{batchSize: 2})
+
+	wantObjects := [][]string{{"a.txt@1", "b.txt@1"}, {"c.txt@2"}}
+	if got := packKeys(objectPacks); len(got) != 2 || !sameStrings(got[0], wantObjects[0]) || !sameStrings(got[1], wantObjects[1]) {
+		t.Errorf("object batches are %v, want %v", got, wantObjects)
+	}
+	if got := packKeys(dirPacks); len(got) != 1 || len(got[0]) != 2 {
+		t.Errorf("directory batches are %v, want the two directory markers in one", got)
+	}
+	if sizes[versionKey(aws.String("b.txt"), aws.String("1"))] != 20 {
+		t.Errorf("sizes are %v, want b.txt to be 20", sizes)
+	}
+}
+
+func TestBatchDeletesLargestFirst(t *testing.T) {
+	list := newObjectList()
+	list.add(newObject("small", "1", 1))
+	list.add(newObject("dir/", "1", 0))
+	list.add(newObject("big", "1", 100))
+
+	objectPacks, dirPacks, _ := batchDeletes(list, deleteOptions{batchSize: maxAWSBatchSize, largestFirst: true})
+	if len(dirPacks) != 0 {
+		t.Errorf("directory markers are batched separately with -largest-first")
+	}
+	want := []string{"big@1", "small@1", "dir/@1"}
+	if got := packKeys(objectPacks); len(got) != 1 || !sameStrings(got[0], want) {
+		t.Errorf("batches are %v, want %v", got, want)
+	}
+}
+
+func TestBatchDeletesMarkDeleted(t *testing.T) {
+	list := newObjectList()
+	list.add(newObject("a", "1", 10))
+
+	objectPacks, _, sizes := batchDeletes(list, deleteOptions{batchSize: maxAWSBatchSize, markDeleted: true})
+	if id := objectPacks[0].Objects[0]; id.VersionId != nil {
+		t.Errorf("version id is %q, want none so a delete marker is added", aws.StringValue(id.VersionId))
+	}
+	if len(sizes) != 0 {
+		t.Errorf("sizes are %v, adding a delete marker frees nothing", sizes)
+	}
+}
+
+func TestBatchDeletesBatchSizeAndByteCap(t *testing.T) {
+	list := newObjectList()
+	long := strings.Repeat("k", 500)
+	for i := 0; i < 6; i++ {
+		list.add(newObject(long+strconv.Itoa(i), "v", 1))
+	}
+	one := identifierSize(&s3.ObjectIdentifier{Key: aws.String(long + "0"), VersionId: aws.String("v")})
+
+	// The byte cap allows three, the batch size two, the smaller wins.
+	objectPacks, _, _ := batchDeletes(list, deleteOptions{batchSize: 2, maxBatchBytes: 3 * one})
+	if got := len(objectPacks); got != 3 {
+		t.Errorf("%d batches, want 3 of 2", got)
+	}
+	// The other way around.
+	objectPacks, _, _ = batchDeletes(list, deleteOptions{batchSize: 5, maxBatchBytes: 3 * one})
+	if got := len(objectPacks); got != 2 {
+		t.Errorf("%d batches, want 2 of 3", got)
+	}
+}
+
+func sameStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestDeleteResultRecordMarkDeleted(t *testing.T) {
+	// S3 answers for a delete without a version id with the marker it added.
+	out := []Result{{Key: "a", DeleteMarker: true}, {Key: "dir/", DeleteMarker: true}}
+
+	result := newDeleteResult(0)
+	result.markDeleted = true
+	result.record(out, nil)
+	if result.DeleteMarkersCreated != 2 || result.DeleteMarkersDeleted != 0 || result.ObjectsDeleted != 0 || result.DirectoriesDeleted != 0 {
+		t.Errorf("created %d, deleted %d markers, %d objects and %d directories, want 2 created and nothing deleted",
+			result.DeleteMarkersCreated, result.DeleteMarkersDeleted, result.ObjectsDeleted, result.DirectoriesDeleted)
+	}
+
+	result = newDeleteResult(0)
+	result.record(out, nil)
+	if result.DeleteMarkersDeleted != 2 || result.DeleteMarkersCreated != 0 {
+		t.Errorf("deleted %d and created %d markers, want 2 deleted", result.DeleteMarkersDeleted, result.DeleteMarkersCreated)
+	}
+}
+
+// batchRecorder keeps the index of every batch and counts the completions.
+type batchRecorder struct {
+	NopObserver
+	lock      sync.Mutex
+	indexes   []int
+	completed int
+}
+
+func (b *batchRecorder) OnBatchDeleted(event BatchEvent) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	b.indexes = append(b.indexes, event.Index)
+}
+
+func (b *batchRecorder) OnComplete(*deleteResult) {
+	b.completed++
+}
+
+func TestDeleteObjectsIntoNumbersBatchesAcrossCalls(t *testing.T) {
+	awsSession := session.Must(session.NewSession(&aws.Config{Region: aws.String("us-east-1")}))
+	recorder := &batchRecorder{}
+	opts := deleteOptions{batchSize: 2, simulate: true, observer: recorder}
+
+	result := newDeleteResult(0)
+	for page := 0; page < 3; page++ {
+		list := newObjectList()
+		for i := 0; i < 3; i++ {
+			list.add(object{Key: "page" + strconv.Itoa(page) + "/" + strconv.Itoa(i), VersionId: "v"})
+		}
+		if err := deleteObjectsInto(awsSession, "bucket", list, opts, result); err != nil {
+			t.Fatal(err)
+		}
+	}
+	sort.Ints(recorder.indexes)
+	if want := []int{1, 2, 3, 4, 5, 6}; !sameInts(recorder.indexes, want) {
+		t.Errorf("batch indexes %v, want %v", recorder.indexes, want)
+	}
+	if result.Batches != 6 || result.ObjectsDeleted != 9 {
+		t.Errorf("%d batches and %d objects deleted, want 6 and 9", result.Batches, result.ObjectsDeleted)
+	}
+	if recorder.completed != 0 {
+		t.Errorf("OnComplete was called %d times, it is up to the caller", recorder.completed)
+	}
+
+	if _, err := deleteObjects(awsSession, "bucket", identifierList("a", "b", "c"), opts); err != nil {
+		t.Fatal(err)
+	}
+	if recorder.completed != 1 {
+		t.Errorf("OnComplete was called %d times by deleteObjects, want 1", recorder.completed)
+	}
+}
+
+func identifierList(keys ...string) *objectList {
+	list := newObjectList()
+	for _, key := range keys {
+		list.add(object{Key: key, VersionId: "v"})
+	}
+	return list
+}
+
+func TestSetupAwsSessionEndpointOnlyForS3(t *testing.T) {
+	t.Setenv("AWS_REGION", "eu-west-1")
+	t.Setenv("AWS_ACCESS_KEY_ID", "id")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "secret")
+	awsSession, err := setupAwsSession(sessionOptions{endpoint: "localhost:4566"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		service string
+		got     string
+		want    string
+	}{
+		{"s3", s3.New(awsSession).Endpoint, "https://localhost:4566"},
+		{"cloudwatch", cloudwatch.New(awsSession).Endpoint, "https://monitoring.eu-west-1.amazonaws.com"},
+		{"sns", sns.New(awsSession).Endpoint, "https://sns.eu-west-1.amazonaws.com"},
+		{"sts", sts.New(awsSession).Endpoint, "https://sts.amazonaws.com"},
+	}
+	for _, tt := range tests {
+		if tt.got != tt.want {
+			t.Errorf("%s endpoint is %s, want %s", tt.service, tt.got, tt.want)
+		}
+	}
+}
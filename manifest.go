@@ -0,0 +1,93 @@
+package main
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+)
+
+// readManifest loads a listing written by -output in one of the JSON formats.
+// Gzipped files, as written by -output-compress, are detected by their .gz
+// extension.
+func readManifest(path string) (*objectList, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var r io.Reader = f
+	if strings.HasSuffix(path, ".gz") {
+		gz, err := gzip.NewReader(f)
+		if err != nil {
+			return nil, err
+		}
+		defer gz.Close()
+		r = gz
+	}
+
+	list := newObjectList()
+	decoder := json.NewDecoder(r)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(list); err != nil {
+		return nil, fmt.Errorf("%s is not a valid manifest: %s", path, err)
+	}
+	if err := list.validateManifest(); err != nil {
+		return nil, fmt.Errorf("%s is not a valid manifest: %s", path, err)
+	}
+	return list, nil
+}
+
+// validateManifest checks a loaded listing is complete enough to delete from.
+func (objList *objectList) validateManifest() error {
+	entries := int64(len(objList.Objects) + len(objList.DeleteMarkers))
+	if objList.ObjectCount != entries {
+		return fmt.Errorf("Length is %d but there are %d objects and delete markers", objList.ObjectCount, entries)
+	}
+	for i, obj := range objList.Objects {
+		if obj.Key == "" || obj.VersionId == "" {
+			return fmt.Errorf("object %d is missing a Key or VersionId", i)
+		}
+	}
+	for i, dm := range objList.DeleteMarkers {
+		if aws.StringValue(dm.Key) == "" || aws.StringValue(dm.VersionId) == "" {
+			return fmt.Errorf("delete marker %d is missing a Key or VersionId", i)
+		}
+	}
+	return nil
+}
+
+// loadManifest reads the manifest and checks it was made for bucket. It warns
+// when the manifest is older than maxAge, as the bucket may have changed
+// since.
+func loadManifest(path, bucket string, maxAge time.Duration) (*objectList, error) {
+	list, err := readManifest(path)
+	if err != nil {
+		return nil, err
+	}
+	if list.Bucket != "" && list.Bucket != bucket {
+		return nil, fmt.Errorf("manifest %s was made for bucket '%s' not '%s'", path, list.Bucket, bucket)
+	}
+
+	switch {
+	case list.GeneratedAt == nil:
+		fmt.Fprintf(os.Stderr, "WARNING: manifest %s does not say when it was made, it may be out of date.\n", path)
+	case time.Since(*list.GeneratedAt) > maxAge:
+		fmt.Fprintf(os.Stderr, "WARNING: manifest %s was made %s ago, the bucket may have changed since.\n", path, time.Since(*list.GeneratedAt).Round(time.Minute))
+	}
+	if list.ObjectCount == 0 {
+		return nil, fmt.Errorf("manifest %s has no objects in it", path)
+	}
+
+	// Only the objects and delete markers are acted on. The uploads and
+	// counts describe the bucket as it was when the manifest was made.
+	list.MultipartUploads = nil
+	list.PrefixCounts = nil
+	return list, nil
+}
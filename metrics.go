@@ -0,0 +1,38 @@
+package main
+
+import (
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/cloudwatch"
+)
+
+// publishMetrics sends the outcome of a run to CloudWatch.
+func publishMetrics(awsSession *session.Session, namespace string, report *runReport) error {
+	dimensions := []*cloudwatch.Dimension{
+		{Name: aws.String("Bucket"), Value: aws.String(report.Bucket)},
+		{Name: aws.String("Region"), Value: aws.String(report.Region)},
+	}
+	now := time.Now()
+	datum := func(name string, value float64, unit string) *cloudwatch.MetricDatum {
+		return &cloudwatch.MetricDatum{
+			MetricName: aws.String(name),
+			Dimensions: dimensions,
+			Timestamp:  aws.Time(now),
+			Unit:       aws.String(unit),
+			Value:      aws.Float64(value),
+		}
+	}
+
+	_, err := cloudwatch.New(awsSession).PutMetricData(&cloudwatch.PutMetricDataInput{
+		Namespace: aws.String(namespace),
+		MetricData: []*cloudwatch.MetricDatum{
+			datum("ObjectsDeleted", float64(report.ObjectsDeleted+report.DirectoriesDeleted), cloudwatch.StandardUnitCount),
+			datum("DeleteMarkersDeleted", float64(report.DeleteMarkersDeleted), cloudwatch.StandardUnitCount),
+			datum("BatchesFailed", float64(report.BatchesFailed), cloudwatch.StandardUnitCount),
+			datum("DurationSeconds", now.Sub(report.StartTime).Seconds(), cloudwatch.StandardUnitSeconds),
+		},
+	})
+	return err
+}
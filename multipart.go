@@ -0,0 +1,79 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+// multipartUpload is an incomplete multipart upload. They are not objects, so
+// are not removed by deleting versions, but still cost money and stop a
+// bucket from being deleted.
+type multipartUpload struct {
+	Key       string     `json:"Key"`
+	UploadId  string     `json:"UploadId"`
+	Initiated *time.Time `json:"Initiated"`
+}
+
+// listMultipartUploads finds the incomplete uploads under the prefixes in
+// opts, or in the whole bucket if there are none, that pass the filters.
+func listMultipartUploads(awsSession *session.Session, bucket string, opts listOptions) ([]multipartUpload, error) {
+	s3Handler := newListingClient(awsSession)
+
+	prefixes := opts.prefixes
+	if len(prefixes) == 0 {
+		prefixes = []string{""}
+	}
+
+	uploads := []multipartUpload{}
+	for _, prefix := range prefixes {
+		input := &s3.ListMultipartUploadsInput{
+			Bucket: aws.String(bucket),
+		}
+		if prefix != "" {
+			input.Prefix = aws.String(prefix)
+		}
+		err := s3Handler.ListMultipartUploadsPages(input, func(page *s3.ListMultipartUploadsOutput, lastPage bool) bool {
+			for _, u := range page.Uploads {
+				if !matchesAll(opts.filters, aws.StringValue(u.Key)) {
+					continue
+				}
+				uploads = append(uploads, multipartUpload{
+					Key:       aws.StringValue(u.Key),
+					UploadId:  aws.StringValue(u.UploadId),
+					Initiated: u.Initiated,
+				})
+			}
+			return true
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return uploads, nil
+}
+
+// abortMultipartUploads aborts each upload, returning how many were aborted
+// and the errors for those that could not be.
+func abortMultipartUploads(awsSession *session.Session, bucket string, uploads []multipartUpload) (int, []string) {
+	s3Handler := s3.New(awsSession)
+
+	aborted := 0
+	errs := []string{}
+	for _, u := range uploads {
+		_, err := s3Handler.AbortMultipartUpload(&s3.AbortMultipartUploadInput{
+			Bucket:   aws.String(bucket),
+			Key:      aws.String(u.Key),
+			UploadId: aws.String(u.UploadId),
+		})
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("%s (upload %s): %s", u.Key, u.UploadId, err))
+			continue
+		}
+		aborted++
+	}
+	return aborted, errs
+}
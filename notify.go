@@ -0,0 +1,100 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/arn"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/sns"
+)
+
+// maxNotificationErrors is how many error messages are put in a notification,
+// SNS messages are limited to 256 KiB.
+const maxNotificationErrors = 20
+
+// notification is the JSON message published by -notify-sns-topic-arn.
+type notification struct {
+	Bucket               string   `json:"Bucket"`
+	CorrelationId        string   `json:"CorrelationId"`
+	Success              bool     `json:"Success"`
+	ExitCode             int      `json:"ExitCode"`
+	ObjectsDeleted       int64    `json:"ObjectsDeleted"`
+	DeleteMarkersDeleted int64    `json:"DeleteMarkersDeleted"`
+	DirectoriesDeleted   int64    `json:"DirectoriesDeleted"`
+	DeleteMarkersCreated int64    `json:"DeleteMarkersCreated"`
+	DeleteFailures       int64    `json:"DeleteFailures"`
+	BatchesFailed        int      `json:"BatchesFailed"`
+	StartTime            string   `json:"StartTime"`
+	EndTime              string   `json:"EndTime"`
+	DurationSeconds      float64  `json:"DurationSeconds"`
+	ErrorCount           int      `json:"ErrorCount"`
+	Errors               []string `json:"Errors"`
+}
+
+func newNotification(report *runReport) notification {
+	errs := report.Errors
+	if len(errs) > maxNotificationErrors {
+		errs = errs[:maxNotificationErrors]
+	}
+	return notification{
+		Bucket:               report.Bucket,
+		CorrelationId:        report.CorrelationId,
+		Success:              report.Success,
+		ExitCode:             report.ExitCode,
+		ObjectsDeleted:       report.ObjectsDeleted,
+		DeleteMarkersDeleted: report.DeleteMarkersDeleted,
+		DirectoriesDeleted:   report.DirectoriesDeleted,
+		DeleteMarkersCreated: report.DeleteMarkersCreated,
+		DeleteFailures:       report.DeleteFailures,
+		BatchesFailed:        report.BatchesFailed,
+		StartTime:            report.StartTime.Format("2006-01-02T15:04:05Z07:00"),
+		EndTime:              report.EndTime.Format("2006-01-02T15:04:05Z07:00"),
+		DurationSeconds:      report.EndTime.Sub(report.StartTime).Seconds(),
+		ErrorCount:           len(report.Errors),
+		Errors:               errs,
+	}
+}
+
+// parseTopicARN checks the ARN is for an SNS topic and returns its region.
+func parseTopicARN(topicARN string) (string, error) {
+	parsed, err := arn.Parse(topicARN)
+	if err != nil {
+		return "", err
+	}
+	if parsed.Service != "sns" {
+		return "", fmt.Errorf("%s is not an SNS topic", topicARN)
+	}
+	return parsed.Region, nil
+}
+
+// publishNotification sends the outcome of a bucket to the SNS topic. The
+// topic can be in any region, the one in its ARN is used.
+func publishNotification(awsSession *session.Session, topicARN string, report *runReport) error {
+	region, err := parseTopicARN(topicARN)
+	if err != nil {
+		return err
+	}
+	message, err := json.Marshal(newNotification(report))
+	if err != nil {
+		return err
+	}
+
+	outcome := "succeeded"
+	if !report.Success {
+		outcome = "failed"
+	}
+	subject := fmt.Sprintf("empty-s3-bucket %s for %s", outcome, report.Bucket)
+	// SNS rejects subjects over 100 characters.
+	if len(subject) > 100 {
+		subject = subject[:100]
+	}
+
+	_, err = sns.New(awsSession, aws.NewConfig().WithRegion(region)).Publish(&sns.PublishInput{
+		TopicArn: aws.String(topicARN),
+		Subject:  aws.String(subject),
+		Message:  aws.String(string(message)),
+	})
+	return err
+}
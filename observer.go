@@ -0,0 +1,140 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+// BatchEvent describes a delete batch. Deleted, Errors, Duration and
+// RequestId are only set once the batch has been sent.
+type BatchEvent struct {
+	// Index counts the batches from 1.
+	Index    int
+	Objects  []*s3.ObjectIdentifier
+	Deleted  int
+	Errors   []*s3.Error
+	Duration time.Duration
+	// RequestId is the S3 request id of the DeleteObjects call, when there
+	// was a single one.
+	RequestId string
+}
+
+// Observer is told about progress through listing and deleting. Listing can
+// run on many goroutines, so OnListPage must be safe to call concurrently.
+type Observer interface {
+	// OnListPage is called with every page returned by ListObjectVersions,
+	// before any filters are applied.
+	OnListPage(page *s3.ListObjectVersionsOutput)
+	// OnBatchStart is called just before a batch is sent.
+	OnBatchStart(event BatchEvent)
+	// OnBatchDeleted is called when the request for a batch succeeded. Some
+	// objects in it may still have failed, see BatchEvent.Errors.
+	OnBatchDeleted(event BatchEvent)
+	// OnBatchError is called when the request for a batch failed.
+	OnBatchError(event BatchEvent, err error)
+	// OnComplete is called once deleting has finished, even if it failed.
+	OnComplete(result *deleteResult)
+}
+
+// NopObserver does nothing. Embed it to only implement the events you need.
+type NopObserver struct{}
+
+func (NopObserver) OnListPage(*s3.ListObjectVersionsOutput) {}
+func (NopObserver) OnBatchStart(BatchEvent)                 {}
+func (NopObserver) OnBatchDeleted(BatchEvent)               {}
+func (NopObserver) OnBatchError(BatchEvent, error)          {}
+func (NopObserver) OnComplete(*deleteResult)                {}
+
+// consoleObserver prints the progress of each batch to w.
+type consoleObserver struct {
+	NopObserver
+	w       io.Writer
+	tagging bool
+}
+
+func (o consoleObserver) OnBatchStart(event BatchEvent) {
+	if o.tagging {
+		fmt.Fprintf(o.w, "Attempting to tag %d objects\n", len(event.Objects))
+		return
+	}
+	fmt.Fprintf(o.w, "Attempting to delete %d objects\n", len(event.Objects))
+}
+
+// verboseObserver prints a line for every batch with the range of keys in
+// it, how long it took and the S3 request id, so a slow or failed batch can
+// be matched up with S3 server access logs or raised with AWS support.
+type verboseObserver struct {
+	NopObserver
+	w io.Writer
+}
+
+func (o verboseObserver) OnBatchDeleted(event BatchEvent) {
+	first, last := batchKeyRange(event.Objects)
+	fmt.Fprintf(o.w, "Batch %d: %d objects from '%s' to '%s', %d deleted, %d failed in %s, request id %s\n",
+		event.Index, len(event.Objects), first, last, event.Deleted, len(event.Errors), event.Duration.Round(time.Millisecond), requestIdOrNone(event.RequestId))
+}
+
+func (o verboseObserver) OnBatchError(event BatchEvent, err error) {
+	first, last := batchKeyRange(event.Objects)
+	fmt.Fprintf(o.w, "Batch %d: %d objects from '%s' to '%s' failed after %s, request id %s. Error: %s\n",
+		event.Index, len(event.Objects), first, last, event.Duration.Round(time.Millisecond), requestIdOrNone(event.RequestId), err)
+}
+
+// batchKeyRange returns the lowest and highest key in a batch. Batches are not
+// always sorted, eg with -largest-first.
+func batchKeyRange(objects []*s3.ObjectIdentifier) (first, last string) {
+	for i, obj := range objects {
+		key := aws.StringValue(obj.Key)
+		if i == 0 || key < first {
+			first = key
+		}
+		if i == 0 || key > last {
+			last = key
+		}
+	}
+	return first, last
+}
+
+func requestIdOrNone(id string) string {
+	if id == "" {
+		return "none"
+	}
+	return id
+}
+
+// multiObserver passes every event on to each of its observers in turn.
+type multiObserver []Observer
+
+func (m multiObserver) OnListPage(page *s3.ListObjectVersionsOutput) {
+	for _, o := range m {
+		o.OnListPage(page)
+	}
+}
+
+func (m multiObserver) OnBatchStart(event BatchEvent) {
+	for _, o := range m {
+		o.OnBatchStart(event)
+	}
+}
+
+func (m multiObserver) OnBatchDeleted(event BatchEvent) {
+	for _, o := range m {
+		o.OnBatchDeleted(event)
+	}
+}
+
+func (m multiObserver) OnBatchError(event BatchEvent, err error) {
+	for _, o := range m {
+		o.OnBatchError(event, err)
+	}
+}
+
+func (m multiObserver) OnComplete(result *deleteResult) {
+	for _, o := range m {
+		o.OnComplete(result)
+	}
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/template"
+
+	"github.com/aws/aws-sdk-go/aws"
+)
+
+// outputOptions changes how the listing is written to files.
+type outputOptions struct {
+	// chunkSize splits the listing over numbered files holding at most this
+	// many entries each. Zero means a single file.
+	chunkSize int
+	// compress gzips each file and adds .gz to the name.
+	compress bool
+	// template is used by -format template.
+	template *template.Template
+}
+
+// writeOutput writes the listing to path in the given format. When chunked,
+// every file is a complete document on its own. It returns the names of the
+// files written.
+func writeOutput(path, format string, list *objectList, opts outputOptions) ([]string, error) {
+	if opts.compress && !strings.HasSuffix(path, ".gz") {
+		path += ".gz"
+	}
+
+	if opts.chunkSize <= 0 {
+		return []string{path}, writeListFile(path, format, list, opts)
+	}
+
+	chunks := list.chunks(opts.chunkSize)
+	files := make([]string, 0, len(chunks))
+	for i, chunk := range chunks {
+		name := chunkFileName(path, i+1)
+		if err := writeListFile(name, format, chunk, opts); err != nil {
+			return files, err
+		}
+		files = append(files, name)
+	}
+	return files, nil
+}
+
+func writeListFile(path, format string, list *objectList, opts outputOptions) (err error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if closeErr := f.Close(); err == nil {
+			err = closeErr
+		}
+	}()
+
+	var w io.Writer = f
+	if opts.compress {
+		gz := gzip.NewWriter(f)
+		defer func() {
+			if closeErr := gz.Close(); err == nil {
+				err = closeErr
+			}
+		}()
+		w = gz
+	}
+
+	switch format {
+	case "parquet":
+		return writeParquet(w, list)
+	case "template":
+		return list.lines(templateWriter(w, opts.template))
+	}
+	_, err = io.WriteString(w, list.toString(format)+"\n")
+	return err
+}
+
+// sortByKey orders the objects, delete markers and multipart uploads by key
+// and then version id or upload id, so the same bucket always gives the same
+// listing whatever order it was listed in.
+func (objList *objectList) sortByKey() {
+	sort.Slice(objList.Objects, func(i, j int) bool {
+		a, b := objList.Objects[i], objList.Objects[j]
+		if a.Key != b.Key {
+			return a.Key < b.Key
+		}
+		return a.VersionId < b.VersionId
+	})
+	sort.Slice(objList.DeleteMarkers, func(i, j int) bool {
+		a, b := objList.DeleteMarkers[i], objList.DeleteMarkers[j]
+		if aws.StringValue(a.Key) != aws.StringValue(b.Key) {
+			return aws.StringValue(a.Key) < aws.StringValue(b.Key)
+		}
+		return aws.StringValue(a.VersionId) < aws.StringValue(b.VersionId)
+	})
+	sort.Slice(objList.MultipartUploads, func(i, j int) bool {
+		a, b := objList.MultipartUploads[i], objList.MultipartUploads[j]
+		if a.Key != b.Key {
+			return a.Key < b.Key
+		}
+		return a.UploadId < b.UploadId
+	})
+}
+
+// chunkFileName numbers a file name, manifest.json becomes manifest-0001.json
+// and manifest.json.gz becomes manifest-0001.json.gz.
+func chunkFileName(path string, n int) string {
+	gz := ""
+	if strings.HasSuffix(path, ".gz") {
+		gz = ".gz"
+		path = strings.TrimSuffix(path, gz)
+	}
+	ext := filepath.Ext(path)
+	return fmt.Sprintf("%s-%04d%s%s", strings.TrimSuffix(path, ext), n, ext, gz)
+}
+
+// chunks splits the list into lists of at most size entries. Objects come
+// first, followed by the delete markers. Multipart uploads all go in the first
+// chunk. An empty list gives a single empty chunk so there is always something
+// to write.
+func (objList *objectList) chunks(size int) []*objectList {
+	result := []*objectList{}
+	current := newObjectList()
+	current.MultipartUploads = objList.MultipartUploads
+	next := func() {
+		current.Bucket, current.GeneratedAt = objList.Bucket, objList.GeneratedAt
+		result = append(result, current)
+		current = newObjectList()
+	}
+
+	for _, obj := range objList.Objects {
+		if current.ObjectCount == int64(size) {
+			next()
+		}
+		current.ObjectCount++
+		current.Objects = append(current.Objects, obj)
+	}
+	for _, dm := range objList.DeleteMarkers {
+		if current.ObjectCount == int64(size) {
+			next()
+		}
+		current.ObjectCount++
+		current.DeleteMarkers = append(current.DeleteMarkers, dm)
+	}
+	next()
+	return result
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"io"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/xitongsys/parquet-go/parquet"
+	"github.com/xitongsys/parquet-go/writer"
+)
+
+// parquetRow is a row in a parquet listing. Object versions and delete
+// markers share the schema and are told apart by Type.
+type parquetRow struct {
+	Key          string `parquet:"name=Key, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
+	VersionId    string `parquet:"name=VersionId, type=BYTE_ARRAY, convertedtype=UTF8"`
+	Size         int64  `parquet:"name=Size, type=INT64"`
+	LastModified *int64 `parquet:"name=LastModified, type=INT64, convertedtype=TIMESTAMP_MILLIS, repetitiontype=OPTIONAL"`
+	StorageClass string `parquet:"name=StorageClass, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
+	IsLatest     bool   `parquet:"name=IsLatest, type=BOOLEAN"`
+	Type         string `parquet:"name=Type, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
+}
+
+const (
+	parquetTypeVersion      = "version"
+	parquetTypeDeleteMarker = "delete-marker"
+)
+
+// writeParquet writes the objects and delete markers in the list to w as a
+// snappy compressed parquet file.
+func writeParquet(w io.Writer, list *objectList) error {
+	pw, err := writer.NewParquetWriterFromWriter(w, new(parquetRow), 1)
+	if err != nil {
+		return err
+	}
+	pw.CompressionType = parquet.CompressionCodec_SNAPPY
+
+	for _, obj := range list.Objects {
+		row := parquetRow{Key: obj.Key, VersionId: obj.VersionId, Size: obj.Size, StorageClass: obj.StorageClass, IsLatest: obj.IsLatest, Type: parquetTypeVersion}
+		if obj.LastModified != nil {
+			row.LastModified = aws.Int64(obj.LastModified.UnixMilli())
+		}
+		if err := pw.Write(row); err != nil {
+			return err
+		}
+	}
+	for _, dm := range list.DeleteMarkers {
+		row := parquetRow{Key: aws.StringValue(dm.Key), VersionId: aws.StringValue(dm.VersionId), IsLatest: aws.BoolValue(dm.IsLatest), Type: parquetTypeDeleteMarker}
+		if dm.LastModified != nil {
+			row.LastModified = aws.Int64(dm.LastModified.UnixMilli())
+		}
+		if err := pw.Write(row); err != nil {
+			return err
+		}
+	}
+	return pw.WriteStop()
+}
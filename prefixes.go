@@ -0,0 +1,101 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+// readPrefixFile reads one prefix per line. Blank lines, comment lines
+// starting with # and repeated prefixes are dropped.
+func readPrefixFile(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	prefixes := []string{}
+	seen := map[string]bool{}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") || seen[line] {
+			continue
+		}
+		seen[line] = true
+		prefixes = append(prefixes, line)
+	}
+	return prefixes, scanner.Err()
+}
+
+// collapsePrefixes drops every prefix that is under another one in the list,
+// as listing it as well would find the same keys twice. The order of the
+// prefixes that are left is kept.
+func collapsePrefixes(prefixes []string) []string {
+	collapsed := make([]string, 0, len(prefixes))
+	for i, prefix := range prefixes {
+		nested := false
+		for j, other := range prefixes {
+			// Of two equal prefixes the first is kept.
+			if strings.HasPrefix(prefix, other) && (len(other) < len(prefix) || j < i) {
+				nested = true
+				break
+			}
+		}
+		if !nested {
+			collapsed = append(collapsed, prefix)
+		}
+	}
+	return collapsed
+}
+
+// prefixCountTable shows how many objects were found under each prefix, in
+// the order the prefixes were given.
+func prefixCountTable(prefixes []string, counts map[string]int64) string {
+	buf := &bytes.Buffer{}
+	w := tabwriter.NewWriter(buf, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "PREFIX\tOBJECTS FOUND")
+	for _, prefix := range prefixes {
+		fmt.Fprintf(w, "%s\t%d\n", prefix, counts[prefix])
+	}
+	w.Flush()
+	return buf.String()
+}
+
+// discoverPrefixes lists the level of the bucket under prefix with a "/"
+// delimiter. It returns the common prefixes found, along with the versions
+// and delete markers of the keys directly under prefix, as those are not in
+// any of the common prefixes.
+func discoverPrefixes(s3Handler *s3.S3, bucket, prefix string, opts listOptions) ([]string, *objectList, error) {
+	input := &s3.ListObjectVersionsInput{
+		Bucket:    aws.String(bucket),
+		Delimiter: aws.String("/"),
+	}
+	if prefix != "" {
+		input.Prefix = aws.String(prefix)
+	}
+
+	prefixes := []string{}
+	top := newObjectList()
+	err := s3Handler.ListObjectVersionsPages(input, func(page *s3.ListObjectVersionsOutput, lastPage bool) bool {
+		if opts.observer != nil {
+			opts.observer.OnListPage(page)
+		}
+		for _, p := range page.CommonPrefixes {
+			prefixes = append(prefixes, aws.StringValue(p.Prefix))
+		}
+		top.addPage(page, keyRange{}, opts)
+		return true
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return prefixes, top, nil
+}
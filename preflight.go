@@ -0,0 +1,88 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3manager"
+)
+
+const bucketRegionHeader = "X-Amz-Bucket-Region"
+
+// checkBucket confirms the bucket exists and is reachable in the region of the
+// session. The errors returned are written to be shown to the user as is.
+func checkBucket(awsSession *session.Session, bucket string) error {
+	req, _ := s3.New(awsSession).HeadBucketRequest(&s3.HeadBucketInput{
+		Bucket: aws.String(bucket),
+	})
+	// S3 answers a HEAD in the wrong region with a 301 without a location
+	// header, we want to see that rather than have it followed.
+	req.DisableFollowRedirects = true
+	bucketRegion := ""
+	req.Handlers.Send.PushBack(func(r *request.Request) {
+		if r.HTTPResponse != nil {
+			bucketRegion = r.HTTPResponse.Header.Get(bucketRegionHeader)
+		}
+	})
+
+	err := req.Send()
+	if err == nil {
+		return nil
+	}
+
+	region := aws.StringValue(awsSession.Config.Region)
+	if reqErr, ok := err.(awserr.RequestFailure); ok {
+		switch reqErr.StatusCode() {
+		case http.StatusNotFound:
+			return fmt.Errorf("bucket '%s' does not exist", bucket)
+		case http.StatusForbidden:
+			return fmt.Errorf("access to bucket '%s' is forbidden, check the bucket policy and your IAM permissions", bucket)
+		case http.StatusMovedPermanently:
+			if bucketRegion != "" {
+				return fmt.Errorf("bucket '%s' is in region '%s' not '%s', use -aws-region %s", bucket, bucketRegion, region, bucketRegion)
+			}
+			return fmt.Errorf("bucket '%s' is not in region '%s', use -aws-region to set the correct region", bucket, region)
+		}
+	}
+	return fmt.Errorf("could not access bucket '%s': %s", bucket, err)
+}
+
+// detectBucketRegion finds the region the bucket lives in, using the region
+// of the session as a hint.
+func detectBucketRegion(awsSession *session.Session, bucket string) (string, error) {
+	return s3manager.GetBucketRegion(aws.BackgroundContext(), awsSession, bucket, aws.StringValue(awsSession.Config.Region))
+}
+
+// expiringLifecycleRules returns the IDs of the enabled lifecycle rules on the
+// bucket that expire objects, versions or delete markers.
+func expiringLifecycleRules(awsSession *session.Session, bucket string) ([]string, error) {
+	out, err := s3.New(awsSession).GetBucketLifecycleConfiguration(&s3.GetBucketLifecycleConfigurationInput{
+		Bucket: aws.String(bucket),
+	})
+	if err != nil {
+		if hasErrorCode(err, "NoSuchLifecycleConfiguration") {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	rules := []string{}
+	for _, rule := range out.Rules {
+		if aws.StringValue(rule.Status) != s3.ExpirationStatusEnabled {
+			continue
+		}
+		if rule.Expiration != nil || rule.NoncurrentVersionExpiration != nil {
+			id := aws.StringValue(rule.ID)
+			if id == "" {
+				id = "(unnamed)"
+			}
+			rules = append(rules, id)
+		}
+	}
+	return rules, nil
+}
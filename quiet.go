@@ -0,0 +1,27 @@
+package main
+
+import (
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+// quietResults fills back in the versions a quiet DeleteObjects response
+// leaves out. Every version in the batch that is not in errs was deleted,
+// and deleteMarkers says which of them were delete markers.
+func quietResults(batch *Batch, errs []*s3.Error, deleteMarkers map[string]bool) []Result {
+	failed := make(map[string]*s3.Error, len(errs))
+	for _, e := range errs {
+		failed[versionKey(e.Key, e.VersionId)] = e
+	}
+	results := make([]Result, 0, len(batch.Objects))
+	for _, id := range batch.Objects {
+		key := versionKey(id.Key, id.VersionId)
+		if e, ok := failed[key]; ok {
+			results = append(results, failedResult(id, e))
+			continue
+		}
+		r := resultFor(id)
+		r.DeleteMarker = deleteMarkers[key]
+		results = append(results, r)
+	}
+	return results
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/aws/session"
+)
+
+// requestTicker paces requests for -max-requests-per-second. It is made once
+// and shared by every session, so the limit covers all buckets together.
+type requestTicker struct {
+	ticker *time.Ticker
+}
+
+func newRequestTicker(perSecond int) *requestTicker {
+	interval := time.Second / time.Duration(perSecond)
+	if interval <= 0 {
+		interval = time.Nanosecond
+	}
+	return &requestTicker{ticker: time.NewTicker(interval)}
+}
+
+// addTo makes every request sent with the session, retries included, wait
+// for a tick first.
+func (t *requestTicker) addTo(awsSession *session.Session) {
+	awsSession.Handlers.Send.PushFront(func(r *request.Request) {
+		select {
+		case <-t.ticker.C:
+		case <-r.Context().Done():
+			r.Error = awserr.New(request.CanceledErrorCode, "request context canceled", r.Context().Err())
+		}
+	})
+}
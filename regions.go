@@ -0,0 +1,53 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// bucketRegionPattern finds the region the SDK adds to a BucketRegionError
+// from the x-amz-bucket-region header of the 301 response.
+var bucketRegionPattern = regexp.MustCompile(`bucket is in '([^']+)' region`)
+
+// wrongRegionHint explains how to fix an error caused by sending requests for
+// a bucket to the wrong region. It returns "" for any other error.
+func wrongRegionHint(err error) string {
+	if !hasErrorCode(err, "BucketRegionError", "PermanentRedirect") {
+		return ""
+	}
+	if m := bucketRegionPattern.FindStringSubmatch(err.Error()); m != nil {
+		return fmt.Sprintf("the bucket is in %s, run again with -aws-region %s", m[1], m[1])
+	}
+	return "the bucket is in a different region, run again with -aws-region set to its region"
+}
+
+// parseRegionMap reads the bucket=region pairs given to -region-per-bucket,
+// separated by commas.
+func parseRegionMap(value string) (map[string]string, error) {
+	regions := map[string]string{}
+	for _, pair := range splitList(value) {
+		parts := strings.SplitN(pair, "=", 2)
+		if len(parts) != 2 || strings.TrimSpace(parts[0]) == "" || strings.TrimSpace(parts[1]) == "" {
+			return nil, fmt.Errorf("%q is not in the form bucket=region", pair)
+		}
+		regions[strings.TrimSpace(parts[0])] = strings.TrimSpace(parts[1])
+	}
+	return regions, nil
+}
+
+// readRegionFile reads a JSON object of bucket names to regions, eg
+// {"logs-eu": "eu-west-1", "logs-us": "us-east-1"}.
+func readRegionFile(path string) (map[string]string, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	regions := map[string]string{}
+	if err := json.Unmarshal(b, &regions); err != nil {
+		return nil, fmt.Errorf("could not parse %s: %s", path, err)
+	}
+	return regions, nil
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+// TestWrongRegionHintFromRedirect sends a request to a server that answers
+// like S3 does for a bucket in another region, so the error is built by the
+// SDK itself.
+func TestWrongRegionHintFromRedirect(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("x-amz-bucket-region", "ap-southeast-2")
+		w.WriteHeader(http.StatusMovedPermanently)
+		w.Write([]byte(`<Error><Code>PermanentRedirect</Code><Message>The bucket you are attempting to access must be addressed using the specified endpoint.</Message></Error>`))
+	}))
+	defer server.Close()
+
+	awsSession := session.Must(session.NewSession(&aws.Config{
+		Region:           aws.String("eu-west-1"),
+		Endpoint:         aws.String(server.URL),
+		S3ForcePathStyle: aws.Bool(true),
+		Credentials:      credentials.NewStaticCredentials("id", "secret", ""),
+		MaxRetries:       aws.Int(0),
+	}))
+	_, err := s3.New(awsSession).ListObjectVersions(&s3.ListObjectVersionsInput{Bucket: aws.String("bucket")})
+	if err == nil {
+		t.Fatal("expected the redirect to fail the request")
+	}
+
+	want := "the bucket is in ap-southeast-2, run again with -aws-region ap-southeast-2"
+	if got := wrongRegionHint(err); got != want {
+		t.Errorf("hint is %q, want %q", got, want)
+	}
+}
+
+func TestWrongRegionHint(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want string
+	}{
+		{
+			"region header",
+			awserr.NewRequestFailure(awserr.New("BucketRegionError", "incorrect region, the bucket is not in 'eu-west-1' region at endpoint '', bucket is in 'us-east-2' region", nil), 301, "id"),
+			"the bucket is in us-east-2, run again with -aws-region us-east-2",
+		},
+		{
+			"no region header",
+			awserr.NewRequestFailure(awserr.New("PermanentRedirect", "The bucket you are attempting to access must be addressed using the specified endpoint.", nil), 301, "id"),
+			"the bucket is in a different region, run again with -aws-region set to its region",
+		},
+		{
+			"other error",
+			awserr.New("AccessDenied", "Access Denied", nil),
+			"",
+		},
+		{
+			"not an AWS error",
+			errors.New("boom"),
+			"",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := wrongRegionHint(tt.err); got != tt.want {
+				t.Errorf("hint is %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
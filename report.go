@@ -0,0 +1,162 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+)
+
+// secretFlags are never written to a report.
+var secretFlags = []string{"secret-access-key", "session-token", "sse-customer-key"}
+
+// runReport is the machine readable record of a run written by -report.
+type runReport struct {
+	Bucket               string            `json:"Bucket"`
+	Region               string            `json:"Region"`
+	CorrelationId        string            `json:"CorrelationId"`
+	StartTime            time.Time         `json:"StartTime"`
+	EndTime              time.Time         `json:"EndTime"`
+	Options              map[string]string `json:"Options"`
+	ObjectsDeleted       int64             `json:"ObjectsDeleted"`
+	DeleteMarkersDeleted int64             `json:"DeleteMarkersDeleted"`
+	DirectoriesDeleted   int64             `json:"DirectoriesDeleted"`
+	DeleteMarkersCreated int64             `json:"DeleteMarkersCreated"`
+	BytesDeleted         int64             `json:"BytesDeleted"`
+	Batches              int               `json:"Batches"`
+	BatchesFailed        int               `json:"BatchesFailed"`
+	// DeleteFailures counts every object that could not be deleted, Errors
+	// only holds the details of the first -max-error-details.
+	DeleteFailures int64 `json:"DeleteFailures"`
+	// DeleteMode is "batch", or "single" when each object needed its own
+	// DeleteObject request.
+	DeleteMode string `json:"DeleteMode"`
+	// ConfigCleared lists the configuration removed by -clear-config.
+	ConfigCleared []string `json:"ConfigCleared,omitempty"`
+	// MultipartUploadsAborted is only set by -abort-multipart-uploads.
+	MultipartUploadsAborted int      `json:"MultipartUploadsAborted"`
+	Errors                  []string `json:"Errors"`
+	Verified                *bool    `json:"Verified"`
+	ExitCode                int      `json:"ExitCode"`
+	Success                 bool     `json:"Success"`
+}
+
+// newRunReport starts a report. It must be called after flag.Parse as it
+// records the value of every flag.
+func newRunReport(bucket, correlationID string) *runReport {
+	options := map[string]string{}
+	flag.VisitAll(func(f *flag.Flag) {
+		value := f.Value.String()
+		if contains(secretFlags, f.Name) && value != "" {
+			value = "REDACTED"
+		}
+		options[f.Name] = value
+	})
+
+	return &runReport{
+		Bucket:        bucket,
+		CorrelationId: correlationID,
+		StartTime:     time.Now().UTC(),
+		Options:       options,
+		Errors:        make([]string, 0),
+	}
+}
+
+func (r *runReport) addError(err error) {
+	r.Errors = append(r.Errors, err.Error())
+}
+
+func (r *runReport) addDeleteResult(result *deleteResult) {
+	if result == nil {
+		return
+	}
+	r.ObjectsDeleted += result.ObjectsDeleted
+	r.DeleteMarkersDeleted += result.DeleteMarkersDeleted
+	r.DirectoriesDeleted += result.DirectoriesDeleted
+	r.DeleteMarkersCreated += result.DeleteMarkersCreated
+	r.BytesDeleted += result.BytesDeleted
+	r.Batches += result.Batches
+	r.BatchesFailed += result.BatchesFailed
+	r.DeleteFailures += result.Failures
+	r.DeleteMode = "batch"
+	if result.SingleDelete {
+		r.DeleteMode = "single"
+	}
+	r.Errors = append(r.Errors, result.Errors...)
+}
+
+func (r *runReport) finish(exitCode int) {
+	r.EndTime = time.Now().UTC()
+	r.ExitCode = exitCode
+	r.Success = exitCode == 0 && len(r.Errors) == 0
+}
+
+func (r *runReport) writeFile(path string) error {
+	b, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(b, '\n'), 0644)
+}
+
+// writeReports writes a single report as it is, and several as a JSON array
+// in the order the buckets were given.
+func writeReports(path string, reports []*runReport) error {
+	if len(reports) == 1 {
+		return reports[0].writeFile(path)
+	}
+	b, err := json.MarshalIndent(reports, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(b, '\n'), 0644)
+}
+
+// bucketSummaryTable shows the region used and the outcome of each bucket.
+func bucketSummaryTable(reports []*runReport) string {
+	buf := &bytes.Buffer{}
+	w := tabwriter.NewWriter(buf, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "BUCKET\tREGION\tDELETED\tEXIT CODE")
+	for _, r := range reports {
+		fmt.Fprintf(w, "%s\t%s\t%d\t%d\n", r.Bucket, r.Region, r.ObjectsDeleted+r.DeleteMarkersDeleted+r.DirectoriesDeleted, r.ExitCode)
+	}
+	w.Flush()
+	return buf.String()
+}
+
+// summary says what was done to the bucket so far, for the end of a run.
+// Tagging runs only count what was tagged.
+func (r *runReport) summary(tagging bool) string {
+	elapsed := time.Since(r.StartTime)
+	total := r.ObjectsDeleted + r.DeleteMarkersDeleted + r.DirectoriesDeleted + r.DeleteMarkersCreated
+	rate := 0.0
+	if elapsed > 0 {
+		rate = float64(total) / elapsed.Seconds()
+	}
+	if elapsed >= time.Minute {
+		elapsed = elapsed.Round(time.Second)
+	} else {
+		elapsed = elapsed.Round(time.Millisecond)
+	}
+
+	buf := &bytes.Buffer{}
+	switch {
+	case tagging:
+		fmt.Fprintf(buf, "Tagged %d objects in bucket '%s' in %s, %.1f objects a second.\n", total, r.Bucket, elapsed, rate)
+	case r.DeleteMarkersCreated > 0:
+		fmt.Fprintf(buf, "Added a delete marker to %d objects in bucket '%s' in %s, %.1f objects a second. Their versions are kept.\n", r.DeleteMarkersCreated, r.Bucket, elapsed, rate)
+	default:
+		fmt.Fprintf(buf, "Deleted %d objects, %d delete markers and %d directories from bucket '%s', freeing %s, in %s, %.1f objects a second.\n",
+			r.ObjectsDeleted, r.DeleteMarkersDeleted, r.DirectoriesDeleted, r.Bucket, humanBytes(r.BytesDeleted), elapsed, rate)
+	}
+	switch {
+	case r.DeleteFailures > 0 && tagging:
+		fmt.Fprintf(buf, "%d objects could not be tagged.\n", r.DeleteFailures)
+	case r.DeleteFailures > 0:
+		fmt.Fprintf(buf, "%d objects could not be deleted.\n", r.DeleteFailures)
+	}
+	return buf.String()
+}
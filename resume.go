@@ -0,0 +1,223 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+// checkpoint records how far a -resume-file run got. Everything listed
+// before the markers has been deleted.
+type checkpoint struct {
+	Bucket          string    `json:"Bucket"`
+	Prefix          string    `json:"Prefix,omitempty"`
+	KeyMarker       string    `json:"KeyMarker"`
+	VersionIdMarker string    `json:"VersionIdMarker"`
+	ObjectsDeleted  int64     `json:"ObjectsDeleted"`
+	UpdatedAt       time.Time `json:"UpdatedAt"`
+}
+
+// readCheckpoint returns nil if there is no checkpoint at path yet.
+func readCheckpoint(path string) (*checkpoint, error) {
+	b, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	cp := &checkpoint{}
+	if err := json.Unmarshal(b, cp); err != nil {
+		return nil, fmt.Errorf("could not parse %s: %s", path, err)
+	}
+	return cp, nil
+}
+
+// writeCheckpoint writes to a temporary file next to path and renames it into
+// place, so a crash part way through never leaves a broken checkpoint.
+func writeCheckpoint(path string, cp *checkpoint) error {
+	b, err := json.MarshalIndent(cp, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(append(b, '\n')); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// resumeOptions changes how deleteResumable behaves.
+type resumeOptions struct {
+	// path is the checkpoint file. No checkpoint is kept when it is empty.
+	path string
+	// skipDeleteMarkers leaves the delete markers in the bucket.
+	skipDeleteMarkers bool
+	// maxInFlight is how many listed objects can be waiting to be deleted
+	// before listing pauses. 0 means no limit.
+	maxInFlight int64
+}
+
+// listedPage is a page handed from the lister to the deleter in
+// deleteResumable, along with the markers for the page after it.
+type listedPage struct {
+	list            *objectList
+	keyMarker       string
+	versionIdMarker string
+	last            bool
+	err             error
+}
+
+// deleteResumable lists and deletes the bucket a page at a time, saving a
+// checkpoint after each page has been deleted. If the checkpoint already
+// exists the listing carries on from it. The checkpoint is removed once the
+// whole bucket has been done, and left alone if anything failed so the
+// failed page is listed again next time. Without a path the bucket is just
+// deleted as it is listed.
+//
+// Listing runs ahead of deleting on its own goroutine, held back by
+// resumeOptions.maxInFlight. Pages are deleted and checkpointed in order.
+func deleteResumable(awsSession *session.Session, bucket string, listOpts listOptions, opts deleteOptions, resume resumeOptions) (*deleteResult, error) {
+	total := newDeleteResult(opts.maxErrorDetails)
+	var cp *checkpoint
+	if resume.path != "" {
+		var err error
+		if cp, err = readCheckpoint(resume.path); err != nil {
+			return total, err
+		}
+	}
+	if cp != nil && cp.Bucket != bucket {
+		return total, fmt.Errorf("resume file %s is for bucket '%s' not '%s'", resume.path, cp.Bucket, bucket)
+	}
+	prefix := ""
+	if len(listOpts.prefixes) == 1 {
+		prefix = listOpts.prefixes[0]
+	}
+	if cp != nil && cp.Prefix != prefix {
+		return total, fmt.Errorf("resume file %s is for prefix '%s' not '%s'", resume.path, cp.Prefix, prefix)
+	}
+	if cp == nil {
+		cp = &checkpoint{Bucket: bucket, Prefix: prefix}
+	} else {
+		fmt.Fprintf(opts.notices(), "Resuming bucket '%s' after key '%s', %d objects were deleted before.\n", bucket, cp.KeyMarker, cp.ObjectsDeleted)
+	}
+
+	// Every page adds to total, so the batches are numbered across the whole
+	// run and the observer hears it is complete once.
+	defer opts.completed(total)
+
+	limiter := newInFlightLimiter(resume.maxInFlight)
+	pages := make(chan listedPage)
+	done := make(chan struct{})
+	defer func() {
+		close(done)
+		limiter.close()
+	}()
+	go listPages(newListingClient(awsSession), bucket, cp.KeyMarker, cp.VersionIdMarker, listOpts, resume.skipDeleteMarkers, limiter, pages, done)
+
+	deletedBefore := cp.ObjectsDeleted
+	for page := range pages {
+		if page.err != nil {
+			return total, &ListError{Bucket: bucket, Err: page.err}
+		}
+		if page.list.ObjectCount > 0 {
+			if err := deleteObjectsInto(awsSession, bucket, page.list, opts, total); err != nil {
+				return total, err
+			}
+		}
+		limiter.release(page.list.ObjectCount)
+
+		if page.last {
+			break
+		}
+		cp.KeyMarker = page.keyMarker
+		cp.VersionIdMarker = page.versionIdMarker
+		cp.ObjectsDeleted = deletedBefore + total.ObjectsDeleted + total.DeleteMarkersDeleted + total.DirectoriesDeleted + total.DeleteMarkersCreated
+		cp.UpdatedAt = time.Now().UTC()
+		if resume.path == "" {
+			continue
+		}
+		if err := writeCheckpoint(resume.path, cp); err != nil {
+			return total, fmt.Errorf("could not save the checkpoint to %s: %s", resume.path, err)
+		}
+	}
+
+	if resume.path == "" {
+		return total, nil
+	}
+	if err := os.Remove(resume.path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return total, err
+	}
+	return total, nil
+}
+
+// listPages lists the bucket from the markers and sends each filtered page to
+// pages, closing it when the listing is finished. It gives up as soon as done
+// is closed, which happens when the deleter stops early.
+func listPages(s3Handler *s3.S3, bucket, keyMarker, versionIdMarker string, listOpts listOptions, skipDeleteMarkers bool, limiter *inFlightLimiter, pages chan<- listedPage, done <-chan struct{}) {
+	defer close(pages)
+	for {
+		input := &s3.ListObjectVersionsInput{Bucket: aws.String(bucket)}
+		// -prefix-file is not allowed with -resume-file, so there is at most
+		// the one from -prefix.
+		if len(listOpts.prefixes) == 1 {
+			input.Prefix = aws.String(listOpts.prefixes[0])
+		}
+		if keyMarker != "" {
+			input.KeyMarker = aws.String(keyMarker)
+			input.VersionIdMarker = aws.String(versionIdMarker)
+		}
+		page, err := s3Handler.ListObjectVersions(input)
+		if err != nil {
+			select {
+			case pages <- listedPage{err: err}:
+			case <-done:
+			}
+			return
+		}
+		if listOpts.observer != nil {
+			listOpts.observer.OnListPage(page)
+		}
+
+		list := newObjectList()
+		list.addPage(page, keyRange{}, listOpts)
+		if skipDeleteMarkers {
+			list.dropDeleteMarkers()
+		}
+		keyMarker = aws.StringValue(page.NextKeyMarker)
+		versionIdMarker = aws.StringValue(page.NextVersionIdMarker)
+		last := !aws.BoolValue(page.IsTruncated)
+
+		if !limiter.acquire(list.ObjectCount) {
+			return
+		}
+		select {
+		case pages <- listedPage{list: list, keyMarker: keyMarker, versionIdMarker: versionIdMarker, last: last}:
+		case <-done:
+			return
+		}
+		if last {
+			return
+		}
+	}
+}
@@ -0,0 +1,29 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// printReview shows what -review-then-delete is about to delete: the totals
+// and the top-level prefixes the objects are spread over.
+func printReview(out io.Writer, bucket string, list *objectList) {
+	s := list.summary()
+	fmt.Fprintf(out, "About to delete from bucket '%s':\n", bucket)
+	fmt.Fprintf(out, "  %d versions of %d keys, %s\n", s.Versions, s.Objects, humanBytes(s.TotalSize))
+	fmt.Fprintf(out, "  %d delete markers\n", s.DeleteMarkers)
+	fmt.Fprint(out, list.breakdown().toTable())
+}
+
+// confirmDelete asks the user to type yes before the listed objects are
+// deleted. Anything else, including the end of the input, is a no.
+func confirmDelete(in io.Reader, out io.Writer, bucket string, count int64) (bool, error) {
+	fmt.Fprintf(out, "Delete these %d entries from bucket '%s'? Type yes to continue: ", count, bucket)
+	scanner := bufio.NewScanner(in)
+	if !scanner.Scan() {
+		return false, scanner.Err()
+	}
+	return strings.EqualFold(strings.TrimSpace(scanner.Text()), "yes"), nil
+}
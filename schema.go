@@ -0,0 +1,103 @@
+package main
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"time"
+)
+
+const jsonSchemaDraft = "https://json-schema.org/draft/2020-12/schema"
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// manifestSchema builds the JSON Schema of a listing written in the json or
+// pretty-json formats. It is worked out from the types so it can not drift
+// from what is really written.
+func manifestSchema() map[string]interface{} {
+	schema := schemaFor(reflect.TypeOf(objectList{}))
+	schema["$schema"] = jsonSchemaDraft
+	schema["title"] = "empty-s3-bucket listing"
+	return schema
+}
+
+func schemaFor(t reflect.Type) map[string]interface{} {
+	if t == timeType {
+		return map[string]interface{}{"type": "string", "format": "date-time"}
+	}
+
+	switch t.Kind() {
+	case reflect.Ptr:
+		schema := schemaFor(t.Elem())
+		// encoding/json writes a nil pointer as null.
+		if kind, ok := schema["type"].(string); ok {
+			schema["type"] = []string{kind, "null"}
+		}
+		return schema
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	case reflect.Slice, reflect.Array:
+		return map[string]interface{}{"type": "array", "items": schemaFor(t.Elem())}
+	case reflect.Map:
+		return map[string]interface{}{"type": "object", "additionalProperties": schemaFor(t.Elem())}
+	case reflect.Struct:
+		properties := map[string]interface{}{}
+		required := []string{}
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			if !field.IsExported() {
+				continue
+			}
+			name, omitEmpty := jsonFieldName(field)
+			if name == "-" {
+				continue
+			}
+			fieldSchema := schemaFor(field.Type)
+			if omitEmpty {
+				// Left out rather than written as null.
+				if kinds, ok := fieldSchema["type"].([]string); ok {
+					fieldSchema["type"] = kinds[0]
+				}
+			} else {
+				required = append(required, name)
+			}
+			properties[name] = fieldSchema
+		}
+		schema := map[string]interface{}{"type": "object", "properties": properties}
+		if len(required) > 0 {
+			schema["required"] = required
+		}
+		return schema
+	}
+	return map[string]interface{}{}
+}
+
+// jsonFieldName returns the name encoding/json uses for the field and if it
+// is left out when empty.
+func jsonFieldName(field reflect.StructField) (string, bool) {
+	tag := field.Tag.Get("json")
+	if tag == "" {
+		return field.Name, false
+	}
+	parts := strings.Split(tag, ",")
+	name := parts[0]
+	if name == "" {
+		name = field.Name
+	}
+	return name, contains(parts[1:], "omitempty")
+}
+
+func manifestSchemaJSON() (string, error) {
+	b, err := json.MarshalIndent(manifestSchema(), "", "  ")
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
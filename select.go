@@ -0,0 +1,264 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/gdamore/tcell/v2"
+	"github.com/mattn/go-runewidth"
+)
+
+const selectHelp = "Up/Down move  Space toggle  p toggle prefix  a all  c clear  PgUp/PgDn page  Enter delete selected  q quit"
+
+// selectEntry is a single row in the selection list. It is either an object
+// version or a delete marker.
+type selectEntry struct {
+	key          string
+	versionId    string
+	object       *object
+	deleteMarker *s3.DeleteMarkerEntry
+	selected     bool
+}
+
+// isTerminal reports if the file is attached to a terminal.
+func isTerminal(f *os.File) bool {
+	stat, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	return stat.Mode()&os.ModeCharDevice != 0
+}
+
+// interactiveSelect lets the user pick which objects to keep in the list
+// from a paged list of checkboxes. It requires stdin and stdout to be a
+// terminal. The returned list only holds the selected objects, ok is false
+// if the user quit.
+func interactiveSelect(list *objectList) (selected *objectList, ok bool, err error) {
+	if !isTerminal(os.Stdin) || !isTerminal(os.Stdout) {
+		return nil, false, fmt.Errorf("-select needs an interactive terminal")
+	}
+	screen, err := tcell.NewScreen()
+	if err != nil {
+		return nil, false, err
+	}
+	if err := screen.Init(); err != nil {
+		return nil, false, err
+	}
+	defer screen.Fini()
+	return selectObjects(list, screen)
+}
+
+// selector is the state of the selection list on screen.
+type selector struct {
+	screen  tcell.Screen
+	entries []*selectEntry
+	cursor  int
+}
+
+// selectObjects runs the selection list on screen until the user is done or
+// quits.
+func selectObjects(list *objectList, screen tcell.Screen) (*objectList, bool, error) {
+	s := &selector{screen: screen, entries: make([]*selectEntry, 0, list.ObjectCount)}
+	for i := range list.Objects {
+		obj := &list.Objects[i]
+		s.entries = append(s.entries, &selectEntry{key: obj.Key, versionId: obj.VersionId, object: obj})
+	}
+	for _, dm := range list.DeleteMarkers {
+		s.entries = append(s.entries, &selectEntry{key: aws.StringValue(dm.Key), versionId: aws.StringValue(dm.VersionId), deleteMarker: dm})
+	}
+
+	for {
+		s.draw()
+		switch ev := screen.PollEvent().(type) {
+		case nil:
+			// The screen was closed.
+			return nil, false, nil
+		case *tcell.EventResize:
+			screen.Sync()
+		case *tcell.EventKey:
+			done, quit := s.handleKey(ev)
+			if quit {
+				return nil, false, nil
+			}
+			if done {
+				return selectedList(s.entries), true, nil
+			}
+		}
+	}
+}
+
+// pageSize is how many entries fit on the screen between the help and the
+// status lines.
+func (s *selector) pageSize() int {
+	_, height := s.screen.Size()
+	if height-2 < 1 {
+		return 1
+	}
+	return height - 2
+}
+
+// handleKey acts on a key press. done is set when the user has finished
+// choosing, quit when they gave up.
+func (s *selector) handleKey(ev *tcell.EventKey) (done, quit bool) {
+	switch ev.Key() {
+	case tcell.KeyEscape, tcell.KeyCtrlC:
+		return false, true
+	case tcell.KeyEnter:
+		return true, false
+	case tcell.KeyUp:
+		s.move(-1)
+	case tcell.KeyDown:
+		s.move(1)
+	case tcell.KeyPgUp:
+		s.move(-s.pageSize())
+	case tcell.KeyPgDn:
+		s.move(s.pageSize())
+	case tcell.KeyHome:
+		s.move(-len(s.entries))
+	case tcell.KeyEnd:
+		s.move(len(s.entries))
+	case tcell.KeyRune:
+		switch ev.Rune() {
+		case 'q':
+			return false, true
+		case 'd':
+			return true, false
+		case 'k':
+			s.move(-1)
+		case 'j':
+			s.move(1)
+		case 'b':
+			s.move(-s.pageSize())
+		case 'n':
+			s.move(s.pageSize())
+		case ' ':
+			if len(s.entries) > 0 {
+				e := s.entries[s.cursor]
+				e.selected = !e.selected
+				s.move(1)
+			}
+		case 'p':
+			s.togglePrefix()
+		case 'a', 'c':
+			for _, e := range s.entries {
+				e.selected = ev.Rune() == 'a'
+			}
+		}
+	}
+	return false, false
+}
+
+func (s *selector) move(by int) {
+	s.cursor += by
+	if s.cursor >= len(s.entries) {
+		s.cursor = len(s.entries) - 1
+	}
+	if s.cursor < 0 {
+		s.cursor = 0
+	}
+}
+
+// togglePrefix selects every entry under the directory of the entry at the
+// cursor, or clears them all if they are all selected already.
+func (s *selector) togglePrefix() {
+	if len(s.entries) == 0 {
+		return
+	}
+	prefix := entryPrefix(s.entries[s.cursor].key)
+	all := true
+	for _, e := range s.entries {
+		if strings.HasPrefix(e.key, prefix) && !e.selected {
+			all = false
+			break
+		}
+	}
+	for _, e := range s.entries {
+		if strings.HasPrefix(e.key, prefix) {
+			e.selected = !all
+		}
+	}
+}
+
+// entryPrefix is the directory a key is in, up to and including the last
+// "/". Keys at the top level have an empty prefix, which covers everything.
+func entryPrefix(key string) string {
+	return key[:strings.LastIndex(key, "/")+1]
+}
+
+func (s *selector) draw() {
+	s.screen.Clear()
+	width, _ := s.screen.Size()
+	plain := tcell.StyleDefault
+	bold := plain.Bold(true)
+	drawText(s.screen, 0, 0, width, bold, selectHelp)
+
+	rows := s.pageSize()
+	page := s.cursor / rows
+	pages := (len(s.entries) + rows - 1) / rows
+	start := page * rows
+	for row := 0; row < rows && start+row < len(s.entries); row++ {
+		i := start + row
+		e := s.entries[i]
+		mark := " "
+		if e.selected {
+			mark = "x"
+		}
+		kind := ""
+		if e.deleteMarker != nil {
+			kind = " (delete marker)"
+		}
+		style := plain
+		if i == s.cursor {
+			style = style.Reverse(true)
+		}
+		drawText(s.screen, 0, row+1, width, style, fmt.Sprintf("[%s] %s  %s%s", mark, e.key, e.versionId, kind))
+	}
+
+	drawText(s.screen, 0, rows+1, width, bold, fmt.Sprintf("Page %d of %d, %d of %d selected", page+1, pages, s.selectedCount(), len(s.entries)))
+	s.screen.Show()
+}
+
+func (s *selector) selectedCount() int {
+	count := 0
+	for _, e := range s.entries {
+		if e.selected {
+			count++
+		}
+	}
+	return count
+}
+
+// drawText writes text on row y from column x, cut off at width.
+func drawText(screen tcell.Screen, x, y, width int, style tcell.Style, text string) {
+	for _, r := range text {
+		w := runewidth.RuneWidth(r)
+		if w < 1 {
+			w = 1
+		}
+		if x+w > width {
+			return
+		}
+		screen.SetContent(x, y, r, nil, style)
+		x += w
+	}
+}
+
+func selectedList(entries []*selectEntry) *objectList {
+	list := newObjectList()
+	markers := []*s3.DeleteMarkerEntry{}
+	for _, e := range entries {
+		if !e.selected {
+			continue
+		}
+		if e.deleteMarker != nil {
+			markers = append(markers, e.deleteMarker)
+			continue
+		}
+		list.add(*e.object)
+	}
+	list.appendDeleteMarkers(markers)
+	return list
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/gdamore/tcell/v2"
+)
+
+func selectTestList() *objectList {
+	list := newObjectList()
+	list.add(object{Key: "a/1", VersionId: "v1"})
+	list.add(object{Key: "a/2", VersionId: "v1"})
+	list.add(object{Key: "b/1", VersionId: "v1"})
+	list.appendDeleteMarkers([]*s3.DeleteMarkerEntry{{Key: aws.String("b/2"), VersionId: aws.String("m1")}})
+	return list
+}
+
+func selectedKeys(list *objectList) []string {
+	keys := []string{}
+	for _, obj := range list.Objects {
+		keys = append(keys, obj.Key)
+	}
+	for _, dm := range list.DeleteMarkers {
+		keys = append(keys, aws.StringValue(dm.Key)+" (delete marker)")
+	}
+	return keys
+}
+
+// key is a key press for a simulated screen. A rune of 0 means the special
+// key in k.
+type key struct {
+	k tcell.Key
+	r rune
+}
+
+func runes(s string) []key {
+	keys := []key{}
+	for _, r := range s {
+		keys = append(keys, key{tcell.KeyRune, r})
+	}
+	return keys
+}
+
+// runSelect drives selectObjects on a simulated screen of the given height
+// with the key presses.
+func runSelect(t *testing.T, list *objectList, height int, keys []key) (*objectList, bool, tcell.SimulationScreen) {
+	t.Helper()
+	screen := tcell.NewSimulationScreen("UTF-8")
+	if err := screen.Init(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(screen.Fini)
+	screen.SetSize(120, height)
+	go func() {
+		for _, k := range keys {
+			screen.PostEventWait(tcell.NewEventKey(k.k, k.r, tcell.ModNone))
+		}
+	}()
+	selected, ok, err := selectObjects(list, screen)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return selected, ok, screen
+}
+
+func screenText(screen tcell.SimulationScreen) string {
+	cells, width, _ := screen.GetContents()
+	b := strings.Builder{}
+	for i, cell := range cells {
+		if len(cell.Runes) > 0 {
+			b.WriteRune(cell.Runes[0])
+		} else {
+			b.WriteRune(' ')
+		}
+		if (i+1)%width == 0 {
+			b.WriteString("\n")
+		}
+	}
+	return b.String()
+}
+
+func TestSelectObjects(t *testing.T) {
+	enter := key{tcell.KeyEnter, 0}
+	down := key{tcell.KeyDown, 0}
+	tests := []struct {
+		name string
+		keys []key
+		want []string
+	}{
+		{"nothing", []key{enter}, []string{}},
+		{"all", append(runes("a"), enter), []string{"a/1", "a/2", "b/1", "b/2 (delete marker)"}},
+		{"clear", append(runes("ac"), enter), []string{}},
+		{"space toggles and moves down", append(runes("  "), enter), []string{"a/1", "a/2"}},
+		{"arrows", []key{down, down, {tcell.KeyRune, ' '}, {tcell.KeyUp, 0}, {tcell.KeyUp, 0}, {tcell.KeyRune, ' '}, enter}, []string{"a/2", "b/1"}},
+		{"toggle twice", append(runes(" k "), enter), []string{}},
+		{"prefix", []key{down, down, {tcell.KeyRune, 'p'}, enter}, []string{"b/1", "b/2 (delete marker)"}},
+		{"prefix again clears it", append(runes("pp"), enter), []string{}},
+		{"d is done", runes("ad"), []string{"a/1", "a/2", "b/1", "b/2 (delete marker)"}},
+		{"moving stops at the ends", append([]key{{tcell.KeyUp, 0}, {tcell.KeyEnd, 0}, down}, append(runes(" "), enter)...), []string{"b/2 (delete marker)"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok, _ := runSelect(t, selectTestList(), 10, tt.keys)
+			if !ok {
+				t.Fatal("selectObjects() quit, want a selection")
+			}
+			if keys := selectedKeys(got); !sameStrings(keys, tt.want) {
+				t.Errorf("selected %v, want %v", keys, tt.want)
+			}
+			if got.ObjectCount != int64(len(tt.want)) {
+				t.Errorf("ObjectCount = %d, want %d", got.ObjectCount, len(tt.want))
+			}
+		})
+	}
+}
+
+func TestSelectObjectsQuit(t *testing.T) {
+	for _, keys := range [][]key{runes("aq"), {{tcell.KeyRune, 'a'}, {tcell.KeyEscape, 0}}, {{tcell.KeyCtrlC, 0}}} {
+		got, ok, _ := runSelect(t, selectTestList(), 10, keys)
+		if ok || got != nil {
+			t.Errorf("keys %v: selectObjects() = %v, %v, want nothing selected", keys, got, ok)
+		}
+	}
+}
+
+func TestSelectObjectsPages(t *testing.T) {
+	list := newObjectList()
+	for _, k := range []string{"k1", "k2", "k3", "k4", "k5", "k6", "k7"} {
+		list.add(object{Key: k, VersionId: "v"})
+	}
+	// A screen of 5 rows has 3 entries a page between the help and status.
+	got, _, screen := runSelect(t, list, 5, []key{{tcell.KeyPgDn, 0}, {tcell.KeyRune, ' '}, {tcell.KeyRune, 'n'}, {tcell.KeyRune, ' '}, {tcell.KeyRune, 'b'}, {tcell.KeyRune, 'd'}})
+	if keys := selectedKeys(got); !sameStrings(keys, []string{"k4", "k7"}) {
+		t.Errorf("selected %v, want k4 and k7", keys)
+	}
+	text := screenText(screen)
+	if !strings.Contains(text, "Page 2 of 3, 2 of 7 selected") || !strings.Contains(text, "[x] k4  v") || !strings.Contains(text, "[ ] k6  v") {
+		t.Errorf("the screen does not show the second page:\n%s", text)
+	}
+}
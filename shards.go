@@ -0,0 +1,24 @@
+package main
+
+// shardAlphabet is split up to make the boundaries between listing shards.
+// Keys are sorted by their UTF-8 bytes, so keys starting with anything else
+// still land in exactly one shard, it just may be a busier one.
+const shardAlphabet = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+
+// shardRanges splits the whole key space into n ranges. Each boundary is a
+// single character, a key equal to it belongs to the range below, anything
+// longer that starts with it belongs to the range above.
+func shardRanges(n int) []keyRange {
+	if n < 2 {
+		return []keyRange{{}}
+	}
+
+	ranges := make([]keyRange, 0, n)
+	after := ""
+	for i := 1; i < n; i++ {
+		boundary := string(shardAlphabet[i*len(shardAlphabet)/n])
+		ranges = append(ranges, keyRange{after: after, upTo: boundary})
+		after = boundary
+	}
+	return append(ranges, keyRange{after: after})
+}
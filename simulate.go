@@ -0,0 +1,29 @@
+package main
+
+import (
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3/s3iface"
+)
+
+// simulatedAction stands in for S3 when running with -simulate. It reports
+// every object as deleted after waiting for latency, without touching the
+// bucket.
+type simulatedAction struct {
+	latency time.Duration
+}
+
+func newSimulatedAction(latency time.Duration) *simulatedAction {
+	return &simulatedAction{latency: latency}
+}
+
+func (a *simulatedAction) Apply(_ aws.Context, _ s3iface.S3API, batch *Batch) ([]Result, error) {
+	time.Sleep(a.latency)
+
+	results := make([]Result, 0, len(batch.Objects))
+	for _, id := range batch.Objects {
+		results = append(results, resultFor(id))
+	}
+	return results, nil
+}
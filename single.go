@@ -0,0 +1,68 @@
+package main
+
+import (
+	"sync"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3iface"
+)
+
+// singleDeleteWorkers is how many objects in a batch are deleted at the same
+// time by the singleDeleteAction.
+const singleDeleteWorkers = 10
+
+// singleDeleteAction stands in for DeleteObjects on S3 compatible stores that
+// do not support it. Each object in the batch is deleted with its own
+// DeleteObject call, so it needs one request per object rather than one per
+// batch.
+type singleDeleteAction struct{}
+
+func (singleDeleteAction) Apply(ctx aws.Context, client s3iface.S3API, batch *Batch) ([]Result, error) {
+	results := make([]Result, 0, len(batch.Objects))
+	lock := sync.Mutex{}
+	work := make(chan *s3.ObjectIdentifier)
+	wg := sync.WaitGroup{}
+	for i := 0; i < singleDeleteWorkers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for id := range work {
+				resp, err := client.DeleteObjectWithContext(ctx, &s3.DeleteObjectInput{
+					Bucket:    aws.String(batch.Bucket),
+					Key:       id.Key,
+					VersionId: id.VersionId,
+				})
+				lock.Lock()
+				if err != nil {
+					results = append(results, failedResult(id, objectError(id, "", err)))
+				} else {
+					r := resultFor(id)
+					r.DeleteMarker = aws.BoolValue(resp.DeleteMarker)
+					results = append(results, r)
+				}
+				lock.Unlock()
+			}
+		}()
+	}
+	for _, id := range batch.Objects {
+		work <- id
+	}
+	close(work)
+	wg.Wait()
+
+	return results, nil
+}
+
+// objectError turns the error from a request for a single version into the
+// error S3 would have given for it in a DeleteObjects response. prefix is
+// put in front of the message.
+func objectError(id *s3.ObjectIdentifier, prefix string, err error) *s3.Error {
+	s3Err := &s3.Error{Key: id.Key, VersionId: id.VersionId, Message: aws.String(prefix + err.Error())}
+	if awsErr, ok := err.(awserr.Error); ok {
+		s3Err.Code = aws.String(awsErr.Code())
+		s3Err.Message = aws.String(prefix + awsErr.Message())
+	}
+	return s3Err
+}
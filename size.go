@@ -0,0 +1,46 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// sizeUnits are the suffixes parseSize understands. KB, MB and so on are
+// powers of 1000, KiB, MiB and so on powers of 1024.
+var sizeUnits = []struct {
+	suffix     string
+	multiplier float64
+}{
+	// Longest first so KiB is not read as a number ending in B.
+	{"KIB", 1 << 10}, {"MIB", 1 << 20}, {"GIB", 1 << 30}, {"TIB", 1 << 40},
+	{"KB", 1e3}, {"MB", 1e6}, {"GB", 1e9}, {"TB", 1e12},
+	{"B", 1},
+}
+
+// parseSize reads a size like 500, 10MB or 1.5GiB as a number of bytes.
+func parseSize(value string) (int64, error) {
+	upper := strings.ToUpper(strings.TrimSpace(value))
+	multiplier := 1.0
+	for _, unit := range sizeUnits {
+		if strings.HasSuffix(upper, unit.suffix) {
+			upper = strings.TrimSpace(strings.TrimSuffix(upper, unit.suffix))
+			multiplier = unit.multiplier
+			break
+		}
+	}
+	n, err := strconv.ParseFloat(upper, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("%q is not a size like 500, 10MB or 1.5GiB", value)
+	}
+	return int64(n * multiplier), nil
+}
+
+// newSizeFilter matches versions of at least min bytes and, if max is not
+// nil, at most *max bytes. A max of 0 only matches empty versions. Delete
+// markers are treated as 0 bytes.
+func newSizeFilter(min int64, max *int64) versionFilter {
+	return func(obj object) bool {
+		return obj.Size >= min && (max == nil || obj.Size <= *max)
+	}
+}
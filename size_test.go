@@ -0,0 +1,66 @@
+package main
+
+import "testing"
+
+func TestParseSize(t *testing.T) {
+	tests := []struct {
+		value string
+		want  int64
+	}{
+		{"0", 0},
+		{"500", 500},
+		{"500B", 500},
+		{"10KB", 10000},
+		{"10kb", 10000},
+		{"10MB", 10000000},
+		{"1GB", 1000000000},
+		{"2TB", 2000000000000},
+		{"1KiB", 1024},
+		{"1.5GiB", 1610612736},
+		{"3MiB", 3 << 20},
+		{"1TiB", 1 << 40},
+		{" 10 MB ", 10000000},
+	}
+	for _, tt := range tests {
+		got, err := parseSize(tt.value)
+		if err != nil {
+			t.Errorf("parseSize(%q) failed: %s", tt.value, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("parseSize(%q) = %d, want %d", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestParseSizeInvalid(t *testing.T) {
+	for _, value := range []string{"", "MB", "ten", "-1", "-5MB", "10XB", "1.5.5GB"} {
+		if got, err := parseSize(value); err == nil {
+			t.Errorf("parseSize(%q) = %d, want an error", value, got)
+		}
+	}
+}
+
+func TestSizeFilter(t *testing.T) {
+	zero, hundred := int64(0), int64(100)
+	tests := []struct {
+		name string
+		min  int64
+		max  *int64
+		want map[int64]bool
+	}{
+		{"no limits", 0, nil, map[int64]bool{0: true, 1: true, 1 << 40: true}},
+		{"min only", 10, nil, map[int64]bool{0: false, 9: false, 10: true, 1 << 40: true}},
+		{"max of 0 is only empty", 0, &zero, map[int64]bool{0: true, 1: false, 100: false}},
+		{"max only", 0, &hundred, map[int64]bool{0: true, 100: true, 101: false}},
+		{"both", 10, &hundred, map[int64]bool{9: false, 10: true, 100: true, 101: false}},
+	}
+	for _, tt := range tests {
+		filter := newSizeFilter(tt.min, tt.max)
+		for size, want := range tt.want {
+			if got := filter(object{Key: "k", Size: size}); got != want {
+				t.Errorf("%s: size %d matched %v, want %v", tt.name, size, got, want)
+			}
+		}
+	}
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+const sseCustomerAlgorithm = "AES256"
+
+// sseCustomerKey is the customer provided key for SSE-C encrypted objects. It
+// is only needed for requests that read object metadata, deleting works
+// without it.
+type sseCustomerKey struct {
+	key    string
+	keyMD5 string
+}
+
+func (k sseCustomerKey) isSet() bool {
+	return k.key != ""
+}
+
+func (k sseCustomerKey) validate() error {
+	if k.key == "" && k.keyMD5 != "" {
+		return fmt.Errorf("-sse-customer-key-md5 requires -sse-customer-key")
+	}
+	if k.key != "" && len(k.key) != 32 {
+		return fmt.Errorf("-sse-customer-key must be 32 bytes for %s, got %d", sseCustomerAlgorithm, len(k.key))
+	}
+	return nil
+}
+
+// applyToHead adds the key to a HeadObject request. The SDK works out the MD5
+// when it is not given.
+func (k sseCustomerKey) applyToHead(input *s3.HeadObjectInput) {
+	if !k.isSet() {
+		return
+	}
+	input.SSECustomerAlgorithm = aws.String(sseCustomerAlgorithm)
+	input.SSECustomerKey = aws.String(k.key)
+	if k.keyMD5 != "" {
+		input.SSECustomerKeyMD5 = aws.String(k.keyMD5)
+	}
+}
+
+// sseCustomerHint explains a failed metadata lookup that looks like it was
+// caused by a missing SSE-C key. S3 answers a HEAD on an SSE-C object without
+// the key with a bare 400.
+func sseCustomerHint(err error, k sseCustomerKey) string {
+	if k.isSet() {
+		return ""
+	}
+	if reqErr, ok := err.(awserr.RequestFailure); ok && reqErr.StatusCode() == http.StatusBadRequest {
+		return "the object may be encrypted with SSE-C, supply the key with -sse-customer-key"
+	}
+	return ""
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// batchStats records how long each delete batch took so latency percentiles
+// and throughput can be shown at the end of the run.
+type batchStats struct {
+	NopObserver
+	lock      sync.Mutex
+	durations []time.Duration
+	deleted   int
+	started   time.Time
+	elapsed   time.Duration
+}
+
+func newBatchStats() *batchStats {
+	return &batchStats{}
+}
+
+func (s *batchStats) OnBatchStart(BatchEvent) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.started.IsZero() {
+		s.started = time.Now()
+	}
+}
+
+func (s *batchStats) OnBatchDeleted(event BatchEvent) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.durations = append(s.durations, event.Duration)
+	s.deleted += event.Deleted
+}
+
+func (s *batchStats) OnBatchError(event BatchEvent, _ error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.durations = append(s.durations, event.Duration)
+}
+
+func (s *batchStats) OnComplete(*deleteResult) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if !s.started.IsZero() {
+		s.elapsed = time.Since(s.started)
+	}
+}
+
+// percentile uses the nearest rank method on already sorted durations.
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	rank := int(p/100*float64(len(sorted))+0.5) - 1
+	if rank < 0 {
+		rank = 0
+	}
+	if rank >= len(sorted) {
+		rank = len(sorted) - 1
+	}
+	return sorted[rank]
+}
+
+func (s *batchStats) String() string {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if len(s.durations) == 0 {
+		return "Batch latency: no batches were sent\n"
+	}
+
+	sorted := make([]time.Duration, len(s.durations))
+	copy(sorted, s.durations)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	var total time.Duration
+	for _, d := range sorted {
+		total += d
+	}
+	mean := total / time.Duration(len(sorted))
+
+	throughput := 0.0
+	if s.elapsed > 0 {
+		throughput = float64(s.deleted) / s.elapsed.Seconds()
+	}
+
+	sb := &strings.Builder{}
+	fmt.Fprintf(sb, "Batch latency over %d batches: min %s, max %s, mean %s, p50 %s, p95 %s\n",
+		len(sorted),
+		sorted[0].Round(time.Millisecond),
+		sorted[len(sorted)-1].Round(time.Millisecond),
+		mean.Round(time.Millisecond),
+		percentile(sorted, 50).Round(time.Millisecond),
+		percentile(sorted, 95).Round(time.Millisecond),
+	)
+	fmt.Fprintf(sb, "Throughput: %d objects in %s, %.1f objects/sec\n", s.deleted, s.elapsed.Round(time.Millisecond), throughput)
+	return sb.String()
+}
@@ -0,0 +1,210 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+// progressTracker counts what has been deleted so far. It is updated by the
+// delete loop and read by the status signal handler, so the counters are only
+// touched atomically.
+type progressTracker struct {
+	NopObserver
+	started time.Time
+	deleted int64
+	batches int64
+	failed  int64
+	// listed counts every version and delete marker listed, before the
+	// filters. total is how many are to be deleted, 0 until it is known.
+	listed int64
+	total  int64
+	// firstBatch is when the first batch was sent, in Unix nanoseconds.
+	firstBatch int64
+}
+
+func newProgressTracker() *progressTracker {
+	return &progressTracker{started: time.Now()}
+}
+
+func (p *progressTracker) OnListPage(page *s3.ListObjectVersionsOutput) {
+	atomic.AddInt64(&p.listed, int64(len(page.Versions)+len(page.DeleteMarkers)))
+}
+
+func (p *progressTracker) OnBatchStart(BatchEvent) {
+	atomic.CompareAndSwapInt64(&p.firstBatch, 0, time.Now().UnixNano())
+}
+
+// setTotal records how many objects are going to be deleted, which gives
+// the progress an ETA.
+func (p *progressTracker) setTotal(total int64) {
+	atomic.StoreInt64(&p.total, total)
+}
+
+func (p *progressTracker) OnBatchDeleted(event BatchEvent) {
+	atomic.AddInt64(&p.deleted, int64(event.Deleted))
+	atomic.AddInt64(&p.batches, 1)
+	if len(event.Errors) > 0 {
+		atomic.AddInt64(&p.failed, 1)
+	}
+}
+
+func (p *progressTracker) OnBatchError(BatchEvent, error) {
+	atomic.AddInt64(&p.batches, 1)
+	atomic.AddInt64(&p.failed, 1)
+}
+
+// status is the line the status signal prints for the bucket.
+func (p *progressTracker) status(bucket string) string {
+	deleted := atomic.LoadInt64(&p.deleted)
+	elapsed := time.Since(p.started)
+	return fmt.Sprintf("Status: %s: %d listed, %d objects deleted in %d batches (%d failed), %s elapsed, %.1f objects/sec\n",
+		bucket,
+		atomic.LoadInt64(&p.listed),
+		deleted,
+		atomic.LoadInt64(&p.batches),
+		atomic.LoadInt64(&p.failed),
+		elapsed.Round(time.Second),
+		float64(deleted)/elapsed.Seconds(),
+	)
+}
+
+// statusBoard holds the progress of every bucket being emptied, so the status
+// signal can report on all of them with -bucket-concurrency.
+type statusBoard struct {
+	lock    sync.Mutex
+	entries []statusEntry
+}
+
+type statusEntry struct {
+	bucket   string
+	progress *progressTracker
+}
+
+// track puts the bucket on the board until untrack is called.
+func (b *statusBoard) track(bucket string, p *progressTracker) (untrack func()) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	b.entries = append(b.entries, statusEntry{bucket: bucket, progress: p})
+	return func() {
+		b.lock.Lock()
+		defer b.lock.Unlock()
+		for i, e := range b.entries {
+			if e.progress == p {
+				b.entries = append(b.entries[:i], b.entries[i+1:]...)
+				return
+			}
+		}
+	}
+}
+
+// status has a line for each bucket on the board, in the order they started.
+func (b *statusBoard) status() string {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	if len(b.entries) == 0 {
+		return "Status: no bucket is being emptied right now\n"
+	}
+	out := ""
+	for _, e := range b.entries {
+		out += e.progress.status(e.bucket)
+	}
+	return out
+}
+
+// watchStatusSignal writes the status of the board to w each time the status
+// signal is received, SIGUSR1 where there is one. It is installed once for
+// the whole run, so the signal never falls back to its default action of
+// ending the process. Call stop when the run is over.
+func watchStatusSignal(w io.Writer, board *statusBoard) (stop func()) {
+	signals := statusSignals()
+	if len(signals) == 0 {
+		return func() {}
+	}
+
+	received := make(chan os.Signal, 1)
+	done := make(chan struct{})
+	signal.Notify(received, signals...)
+	go func() {
+		for {
+			select {
+			case <-received:
+				fmt.Fprint(w, board.status())
+			case <-done:
+				return
+			}
+		}
+	}()
+	return func() {
+		signal.Stop(received)
+		close(done)
+	}
+}
+
+// progressLine is a one line summary for -progress. The rate is counted from
+// the first batch so the time spent listing does not drag it down.
+func (p *progressTracker) progressLine(bucket string) string {
+	deleted := atomic.LoadInt64(&p.deleted)
+	line := fmt.Sprintf("%s: %d listed, %d deleted", bucket, atomic.LoadInt64(&p.listed), deleted)
+	if total := atomic.LoadInt64(&p.total); total > 0 {
+		line += fmt.Sprintf(" of %d (%.1f%%)", total, float64(deleted)/float64(total)*100)
+	}
+	first := atomic.LoadInt64(&p.firstBatch)
+	if first == 0 {
+		return line + fmt.Sprintf(", %s elapsed", time.Since(p.started).Round(time.Second))
+	}
+	rate := float64(deleted) / time.Since(time.Unix(0, first)).Seconds()
+	line += fmt.Sprintf(", %.1f objects/sec", rate)
+	if total := atomic.LoadInt64(&p.total); total > deleted && rate > 0 {
+		eta := time.Duration(float64(total-deleted) / rate * float64(time.Second))
+		line += fmt.Sprintf(", ETA %s", eta.Round(time.Second))
+	}
+	return line
+}
+
+// showProgress writes the progress to w. With redraw, for a terminal, the
+// same line is updated every second, otherwise a new line is written every 30
+// seconds. stop prints the last update and can be called more than once.
+func showProgress(w io.Writer, bucket string, p *progressTracker, redraw bool) (stop func()) {
+	interval, format := 30*time.Second, "%s\n"
+	if redraw {
+		interval, format = time.Second, "\r%s\033[K"
+	}
+	print := func(out io.Writer) {
+		fmt.Fprintf(out, format, p.progressLine(bucket))
+	}
+
+	done := make(chan struct{})
+	finished := make(chan struct{})
+	go func() {
+		defer close(finished)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				print(w)
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	once := sync.Once{}
+	return func() {
+		once.Do(func() {
+			close(done)
+			<-finished
+			print(w)
+			if redraw {
+				fmt.Fprintln(w)
+			}
+		})
+	}
+}
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestStatusBoard(t *testing.T) {
+	board := &statusBoard{}
+	if got := board.status(); got != "Status: no bucket is being emptied right now\n" {
+		t.Errorf("empty board status = %q", got)
+	}
+
+	first, second := newProgressTracker(), newProgressTracker()
+	untrackFirst := board.track("first", first)
+	untrackSecond := board.track("second", second)
+	second.OnBatchDeleted(BatchEvent{Deleted: 5})
+
+	lines := strings.Split(strings.TrimSuffix(board.status(), "\n"), "\n")
+	if len(lines) != 2 || !strings.HasPrefix(lines[0], "Status: first: 0 listed, 0 objects deleted") || !strings.HasPrefix(lines[1], "Status: second: 0 listed, 5 objects deleted in 1 batches") {
+		t.Errorf("status does not have a line for each bucket:\n%s", strings.Join(lines, "\n"))
+	}
+
+	untrackFirst()
+	if got := board.status(); strings.Contains(got, "first") || !strings.Contains(got, "second") {
+		t.Errorf("status after the first bucket finished:\n%s", got)
+	}
+	untrackSecond()
+	if got := board.status(); strings.Contains(got, "second") {
+		t.Errorf("status after both buckets finished:\n%s", got)
+	}
+}
@@ -0,0 +1,12 @@
+//go:build !windows
+
+package main
+
+import (
+	"os"
+	"syscall"
+)
+
+func statusSignals() []os.Signal {
+	return []os.Signal{syscall.SIGUSR1}
+}
@@ -0,0 +1,57 @@
+//go:build !windows
+
+package main
+
+import (
+	"bytes"
+	"strings"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// lockedBuffer is written by the signal goroutine and read by the test.
+type lockedBuffer struct {
+	lock sync.Mutex
+	buf  bytes.Buffer
+}
+
+func (b *lockedBuffer) Write(p []byte) (int, error) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *lockedBuffer) String() string {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	return b.buf.String()
+}
+
+// Without a bucket on the board the signal is still handled, rather than
+// ending the test binary.
+func TestWatchStatusSignal(t *testing.T) {
+	out := &lockedBuffer{}
+	board := &statusBoard{}
+	stop := watchStatusSignal(out, board)
+	defer stop()
+
+	waitFor := func(want string) {
+		t.Helper()
+		if err := syscall.Kill(syscall.Getpid(), syscall.SIGUSR1); err != nil {
+			t.Fatal(err)
+		}
+		deadline := time.Now().Add(5 * time.Second)
+		for !strings.Contains(out.String(), want) {
+			if time.Now().After(deadline) {
+				t.Fatalf("no %q in the status output:\n%s", want, out)
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+	waitFor("no bucket is being emptied")
+
+	defer board.track("my-bucket", newProgressTracker())()
+	waitFor("Status: my-bucket: ")
+}
@@ -0,0 +1,10 @@
+//go:build windows
+
+package main
+
+import "os"
+
+// statusSignals is empty as Windows has no SIGUSR1.
+func statusSignals() []os.Signal {
+	return nil
+}
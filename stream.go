@@ -0,0 +1,60 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"sync"
+
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+// batchRecord is a single line written by -stream-results.
+type batchRecord struct {
+	Batch      int         `json:"Batch"`
+	Attempted  int         `json:"Attempted"`
+	Deleted    int         `json:"Deleted"`
+	Errors     []*s3.Error `json:"Errors,omitempty"`
+	Error      string      `json:"Error,omitempty"`
+	DurationMs int64       `json:"DurationMs"`
+}
+
+// streamObserver writes a JSON record to out as each batch finishes, one per
+// line. Every record is written in a single call so lines never interleave.
+type streamObserver struct {
+	NopObserver
+	lock sync.Mutex
+	out  io.Writer
+}
+
+func newStreamObserver(out io.Writer) *streamObserver {
+	return &streamObserver{out: out}
+}
+
+func (o *streamObserver) OnBatchDeleted(event BatchEvent) {
+	o.write(batchRecord{
+		Batch:      event.Index,
+		Attempted:  len(event.Objects),
+		Deleted:    event.Deleted,
+		Errors:     event.Errors,
+		DurationMs: event.Duration.Milliseconds(),
+	})
+}
+
+func (o *streamObserver) OnBatchError(event BatchEvent, err error) {
+	o.write(batchRecord{
+		Batch:      event.Index,
+		Attempted:  len(event.Objects),
+		Error:      err.Error(),
+		DurationMs: event.Duration.Milliseconds(),
+	})
+}
+
+func (o *streamObserver) write(record batchRecord) {
+	b, err := json.Marshal(record)
+	if err != nil {
+		return
+	}
+	o.lock.Lock()
+	defer o.lock.Unlock()
+	o.out.Write(append(b, '\n'))
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go/aws"
+)
+
+// listSummary is the short version of an objectList used by
+// -show-summary-only.
+type listSummary struct {
+	// Objects is the number of distinct keys.
+	Objects       int64 `json:"Objects"`
+	Versions      int64 `json:"Versions"`
+	DeleteMarkers int64 `json:"DeleteMarkers"`
+	TotalSize     int64 `json:"TotalSize"`
+}
+
+func (objList *objectList) summary() listSummary {
+	keys := map[string]struct{}{}
+	s := listSummary{
+		Versions:      int64(len(objList.Objects)),
+		DeleteMarkers: int64(len(objList.DeleteMarkers)),
+	}
+	for _, obj := range objList.Objects {
+		keys[obj.Key] = struct{}{}
+		s.TotalSize += obj.Size
+	}
+	for _, dm := range objList.DeleteMarkers {
+		keys[aws.StringValue(dm.Key)] = struct{}{}
+	}
+	s.Objects = int64(len(keys))
+	return s
+}
+
+func (s listSummary) toString(format string) string {
+	switch format {
+	case "json", "ndjson":
+		b, _ := json.Marshal(s)
+		return string(b)
+	case "pretty-json":
+		b, _ := json.MarshalIndent(s, "", "  ")
+		return string(b)
+	case "yaml":
+		return marshalYAML(s)
+	case "table":
+		return s.toTable()
+	case "csv":
+		return fmt.Sprintf("Objects,Versions,DeleteMarkers,TotalSize\n%d,%d,%d,%d", s.Objects, s.Versions, s.DeleteMarkers, s.TotalSize)
+	}
+	// We should never get here as formats are checked BEFORE execution.
+	return ""
+}
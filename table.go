@@ -0,0 +1,55 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+)
+
+// toTable lines the listing up in columns for reading on a terminal, with the
+// totals at the end. Ages are counted back from now.
+func (objList *objectList) toTable(now time.Time) string {
+	buf := &bytes.Buffer{}
+	w := tabwriter.NewWriter(buf, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "KEY\tVERSION\tSIZE\tAGE")
+	var size int64
+	for _, obj := range objList.Objects {
+		size += obj.Size
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", obj.Key, obj.VersionId, humanBytes(obj.Size), ageOf(now, obj.LastModified))
+	}
+	for _, dm := range objList.DeleteMarkers {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", aws.StringValue(dm.Key), aws.StringValue(dm.VersionId), "delete marker", ageOf(now, dm.LastModified))
+	}
+	w.Flush()
+	fmt.Fprintf(buf, "\nTotal: %d versions, %d delete markers, %s", len(objList.Objects), len(objList.DeleteMarkers), humanBytes(size))
+	return buf.String()
+}
+
+func (s listSummary) toTable() string {
+	buf := &bytes.Buffer{}
+	w := tabwriter.NewWriter(buf, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "OBJECTS\tVERSIONS\tDELETE MARKERS\tTOTAL SIZE")
+	fmt.Fprintf(w, "%d\t%d\t%d\t%s\n", s.Objects, s.Versions, s.DeleteMarkers, humanBytes(s.TotalSize))
+	w.Flush()
+	return strings.TrimSuffix(buf.String(), "\n")
+}
+
+// ageOf says roughly how long ago t was, in minutes, hours or days. It is "-"
+// when t is not known, which only happens with older manifests.
+func ageOf(now time.Time, t *time.Time) string {
+	if t == nil {
+		return "-"
+	}
+	d := now.Sub(*t)
+	switch {
+	case d < time.Hour:
+		return fmt.Sprintf("%dm", int(d.Minutes()))
+	case d < 48*time.Hour:
+		return fmt.Sprintf("%dh", int(d.Hours()))
+	}
+	return fmt.Sprintf("%dd", int(d.Hours()/24))
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+// keepOnlyTagged looks up the tags of every version in the list, workers at
+// a time, and removes the versions that do not have the tag. It returns how
+// many were removed. A version whose tags can not be read is removed too, so
+// nothing is deleted unless it is known to have the tag, and the error is
+// returned. Delete markers have no tags and must already be gone.
+func keepOnlyTagged(awsSession *session.Session, bucket string, list *objectList, tag *s3.Tag, workers int) (int64, []error) {
+	s3Handler := s3.New(awsSession)
+	matches := make([]bool, len(list.Objects))
+	errs := []error{}
+	errsLock := sync.Mutex{}
+
+	work := make(chan int)
+	wg := sync.WaitGroup{}
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range work {
+				obj := list.Objects[i]
+				out, err := s3Handler.GetObjectTagging(&s3.GetObjectTaggingInput{
+					Bucket:    aws.String(bucket),
+					Key:       aws.String(obj.Key),
+					VersionId: aws.String(obj.VersionId),
+				})
+				if err != nil {
+					errsLock.Lock()
+					errs = append(errs, fmt.Errorf("%s (%s): %s", obj.Key, obj.VersionId, err))
+					errsLock.Unlock()
+					continue
+				}
+				for _, t := range out.TagSet {
+					if aws.StringValue(t.Key) == aws.StringValue(tag.Key) && aws.StringValue(t.Value) == aws.StringValue(tag.Value) {
+						matches[i] = true
+						break
+					}
+				}
+			}
+		}()
+	}
+	for i := range list.Objects {
+		work <- i
+	}
+	close(work)
+	wg.Wait()
+
+	list.lock.Lock()
+	defer list.lock.Unlock()
+	remaining := make([]object, 0, len(list.Objects))
+	for i, obj := range list.Objects {
+		if matches[i] {
+			remaining = append(remaining, obj)
+		}
+	}
+	removed := int64(len(list.Objects) - len(remaining))
+	list.Objects = remaining
+	list.ObjectCount -= removed
+	// The counts per prefix were taken before the tags were checked.
+	list.PrefixCounts = nil
+	return removed, errs
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3iface"
+)
+
+// taggingWorkers is how many objects in a batch are tagged at the same time.
+const taggingWorkers = 10
+
+// parseTag splits key=value.
+func parseTag(tag string) (*s3.Tag, error) {
+	parts := strings.SplitN(tag, "=", 2)
+	if len(parts) != 2 || parts[0] == "" {
+		return nil, fmt.Errorf("%q is not in the form key=value", tag)
+	}
+	return &s3.Tag{Key: aws.String(parts[0]), Value: aws.String(parts[1])}, nil
+}
+
+// taggingAction stands in for deleting when running with -tag-for-deletion.
+// Rather than deleting, it adds a tag to each object version so a lifecycle
+// rule can expire it. Existing tags are kept. Objects it tags are reported
+// as dealt with and failures as errors, so the rest of the delete process
+// works unchanged.
+type taggingAction struct {
+	tag *s3.Tag
+}
+
+func newTaggingAction(tag *s3.Tag) *taggingAction {
+	return &taggingAction{tag: tag}
+}
+
+func (a *taggingAction) Apply(_ aws.Context, client s3iface.S3API, batch *Batch) ([]Result, error) {
+	results := make([]Result, 0, len(batch.Objects))
+	lock := sync.Mutex{}
+	work := make(chan *s3.ObjectIdentifier)
+	wg := sync.WaitGroup{}
+	for i := 0; i < taggingWorkers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for id := range work {
+				err := a.tagObject(client, batch.Bucket, id)
+				lock.Lock()
+				if err != nil {
+					results = append(results, failedResult(id, &s3.Error{
+						Key:       id.Key,
+						VersionId: id.VersionId,
+						Message:   aws.String(err.Error()),
+					}))
+				} else {
+					results = append(results, resultFor(id))
+				}
+				lock.Unlock()
+			}
+		}()
+	}
+	for _, id := range batch.Objects {
+		work <- id
+	}
+	close(work)
+	wg.Wait()
+
+	return results, nil
+}
+
+func (a *taggingAction) tagObject(client s3iface.S3API, bucket string, id *s3.ObjectIdentifier) error {
+	current, err := client.GetObjectTagging(&s3.GetObjectTaggingInput{
+		Bucket:    aws.String(bucket),
+		Key:       id.Key,
+		VersionId: id.VersionId,
+	})
+	if err != nil {
+		return err
+	}
+
+	tags := []*s3.Tag{a.tag}
+	for _, t := range current.TagSet {
+		if aws.StringValue(t.Key) != aws.StringValue(a.tag.Key) {
+			tags = append(tags, t)
+		}
+	}
+
+	_, err = client.PutObjectTagging(&s3.PutObjectTaggingInput{
+		Bucket:    aws.String(bucket),
+		Key:       id.Key,
+		VersionId: id.VersionId,
+		Tagging:   &s3.Tagging{TagSet: tags},
+	})
+	return err
+}
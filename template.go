@@ -0,0 +1,24 @@
+package main
+
+import (
+	"io"
+	"text/template"
+)
+
+// parseLineTemplate parses a -template. It is run once for each version and
+// delete marker with the fields of a listingLine, eg {{.Key}} {{.Size}}, and
+// each run ends with a newline.
+func parseLineTemplate(text string) (*template.Template, error) {
+	return template.New("template").Parse(text)
+}
+
+// templateWriter writes each line through tmpl.
+func templateWriter(w io.Writer, tmpl *template.Template) func(listingLine) error {
+	return func(line listingLine) error {
+		if err := tmpl.Execute(w, line); err != nil {
+			return err
+		}
+		_, err := io.WriteString(w, "\n")
+		return err
+	}
+}
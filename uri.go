@@ -0,0 +1,34 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// parseBucketArgs turns bucket names and s3://bucket/prefix URIs into the
+// bucket names and the prefix from the URIs. Every URI with a prefix must
+// have the same one, as the prefix applies to the whole run.
+func parseBucketArgs(args []string) (buckets []string, prefix string, err error) {
+	buckets = make([]string, 0, len(args))
+	for _, arg := range args {
+		if strings.HasPrefix(arg, "-") {
+			return nil, "", fmt.Errorf("%s looks like a flag, flags must come before the buckets", arg)
+		}
+		if !strings.HasPrefix(arg, "s3://") {
+			buckets = append(buckets, arg)
+			continue
+		}
+		bucket, uriPrefix, _ := strings.Cut(strings.TrimPrefix(arg, "s3://"), "/")
+		if bucket == "" {
+			return nil, "", fmt.Errorf("%s has no bucket name", arg)
+		}
+		if uriPrefix != "" {
+			if prefix != "" && prefix != uriPrefix {
+				return nil, "", fmt.Errorf("the s3:// URIs have different prefixes, %q and %q, only one prefix can be used per run", prefix, uriPrefix)
+			}
+			prefix = uriPrefix
+		}
+		buckets = append(buckets, bucket)
+	}
+	return buckets, prefix, nil
+}
@@ -0,0 +1,44 @@
+package main
+
+import "testing"
+
+func TestParseBucketArgs(t *testing.T) {
+	tests := []struct {
+		name        string
+		args        []string
+		wantBuckets []string
+		wantPrefix  string
+	}{
+		{"names", []string{"a", "b"}, []string{"a", "b"}, ""},
+		{"uri without prefix", []string{"s3://a"}, []string{"a"}, ""},
+		{"uri with only a slash", []string{"s3://a/"}, []string{"a"}, ""},
+		{"uri with prefix", []string{"s3://a/logs/2023/"}, []string{"a"}, "logs/2023/"},
+		{"same prefix twice", []string{"s3://a/logs/", "s3://b/logs/"}, []string{"a", "b"}, "logs/"},
+		{"uri and name", []string{"s3://a/logs/", "b"}, []string{"a", "b"}, "logs/"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			buckets, prefix, err := parseBucketArgs(tt.args)
+			if err != nil {
+				t.Fatalf("failed: %s", err)
+			}
+			if !sameStrings(buckets, tt.wantBuckets) || prefix != tt.wantPrefix {
+				t.Fatalf("got %v %q, want %v %q", buckets, prefix, tt.wantBuckets, tt.wantPrefix)
+			}
+		})
+	}
+}
+
+func TestParseBucketArgsInvalid(t *testing.T) {
+	tests := [][]string{
+		{"a", "-dry-run"},
+		{"s3://"},
+		{"s3:///logs/"},
+		{"s3://a/logs/", "s3://b/backups/"},
+	}
+	for _, args := range tests {
+		if buckets, prefix, err := parseBucketArgs(args); err == nil {
+			t.Errorf("parseBucketArgs(%v) = %v %q, want an error", args, buckets, prefix)
+		}
+	}
+}
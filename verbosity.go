@@ -0,0 +1,77 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+
+	"github.com/aws/aws-sdk-go/aws"
+)
+
+// Verbosity levels used by -verbose.
+const (
+	// verboseInfo prints extra detail such as the identity and region used.
+	verboseInfo = 1
+	// verboseRequests adds SDK logging of retries and failed requests.
+	verboseRequests = 2
+	// verboseWire adds SDK logging of every request and response, bodies
+	// included.
+	verboseWire = 3
+)
+
+// verbosityFlag is the value of -verbose. On its own the flag means level 1,
+// like the bool flag it replaced, and -verbose=N picks a level.
+type verbosityFlag int
+
+func (v *verbosityFlag) String() string {
+	if v == nil {
+		return "0"
+	}
+	return strconv.Itoa(int(*v))
+}
+
+func (v *verbosityFlag) Set(value string) error {
+	switch value {
+	case "true":
+		*v = verboseInfo
+		return nil
+	case "false":
+		*v = 0
+		return nil
+	}
+	level, err := strconv.Atoi(value)
+	if err != nil || level < 0 || level > verboseWire {
+		return fmt.Errorf("must be true, false or a level from 0 to %d", verboseWire)
+	}
+	*v = verbosityFlag(level)
+	return nil
+}
+
+// IsBoolFlag lets -verbose be given without a value.
+func (v *verbosityFlag) IsBoolFlag() bool {
+	return true
+}
+
+// sdkLogLevel is the AWS SDK log level for the verbosity.
+func (v verbosityFlag) sdkLogLevel() aws.LogLevelType {
+	switch {
+	case v >= verboseWire:
+		return aws.LogDebugWithHTTPBody | aws.LogDebugWithRequestRetries | aws.LogDebugWithRequestErrors
+	case v >= verboseRequests:
+		return aws.LogDebugWithRequestRetries | aws.LogDebugWithRequestErrors
+	}
+	return aws.LogOff
+}
+
+// applyToConfig turns on SDK logging for the higher levels. The SDK logs to
+// stdout by default, this sends it to stderr so listings on stdout stay
+// clean.
+func (v verbosityFlag) applyToConfig(config *aws.Config) {
+	if v < verboseRequests {
+		return
+	}
+	config.LogLevel = aws.LogLevel(v.sdkLogLevel())
+	config.Logger = aws.LoggerFunc(func(args ...interface{}) {
+		fmt.Fprintln(os.Stderr, args...)
+	})
+}
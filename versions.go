@@ -0,0 +1,61 @@
+package main
+
+import (
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+// keepNewestVersions removes the newest n versions of each key from the list
+// so they are not deleted, and returns how many were removed. It relies on
+// ListObjectVersions returning the versions of a key newest first, which the
+// listing keeps as each key is only ever listed by one range. A manifest need
+// not be in that order, so it is not used with -from-manifest.
+func (objList *objectList) keepNewestVersions(n int) int64 {
+	objList.lock.Lock()
+	defer objList.lock.Unlock()
+
+	seen := map[string]int{}
+	versions := map[string]struct{}{}
+	remaining := make([]object, 0, len(objList.Objects))
+	kept := int64(0)
+	duplicates := int64(0)
+	for _, obj := range objList.Objects {
+		// Overlapping prefixes can list the same version twice. The copy is
+		// dropped, keeping it would delete a version that is meant to stay.
+		id := obj.Key + "\x00" + obj.VersionId
+		if _, ok := versions[id]; ok {
+			duplicates++
+			continue
+		}
+		versions[id] = struct{}{}
+
+		if seen[obj.Key] < n {
+			seen[obj.Key]++
+			kept++
+			continue
+		}
+		remaining = append(remaining, obj)
+	}
+	objList.Objects = remaining
+	objList.ObjectCount -= kept + duplicates
+	return kept
+}
+
+// keepLatestDeleteMarkers removes the delete markers that are the current
+// version of their key from the list, and returns how many were removed.
+// Deleting one of those would bring back the object it hides.
+func (objList *objectList) keepLatestDeleteMarkers() int64 {
+	objList.lock.Lock()
+	defer objList.lock.Unlock()
+
+	remaining := make([]*s3.DeleteMarkerEntry, 0, len(objList.DeleteMarkers))
+	for _, dm := range objList.DeleteMarkers {
+		if !aws.BoolValue(dm.IsLatest) {
+			remaining = append(remaining, dm)
+		}
+	}
+	kept := int64(len(objList.DeleteMarkers) - len(remaining))
+	objList.DeleteMarkers = remaining
+	objList.ObjectCount -= kept
+	return kept
+}
@@ -0,0 +1,39 @@
+package main
+
+import "testing"
+
+func TestKeepNewestVersionsDuplicatedListing(t *testing.T) {
+	list := newObjectList()
+	// Overlapping prefixes list every version of the key twice.
+	for i := 0; i < 2; i++ {
+		for _, v := range []string{"v3", "v2", "v1"} {
+			list.add(newObject("a", v, 1))
+		}
+	}
+
+	kept := list.keepNewestVersions(2)
+	if kept != 2 {
+		t.Errorf("kept %d versions, want 2", kept)
+	}
+	if len(list.Objects) != 1 || list.Objects[0].VersionId != "v1" {
+		t.Fatalf("versions to delete are %v, want only v1", list.Objects)
+	}
+	if list.ObjectCount != 1 {
+		t.Errorf("ObjectCount is %d, want 1", list.ObjectCount)
+	}
+}
+
+func TestKeepNewestVersions(t *testing.T) {
+	list := newObjectList()
+	for _, v := range []string{"v3", "v2", "v1"} {
+		list.add(newObject("a", v, 1))
+	}
+	list.add(newObject("b", "v1", 1))
+
+	if kept := list.keepNewestVersions(1); kept != 2 {
+		t.Errorf("kept %d versions, want 2", kept)
+	}
+	if len(list.Objects) != 2 || list.Objects[0].VersionId != "v2" || list.Objects[1].VersionId != "v1" {
+		t.Errorf("versions to delete are %v, want a v2 and a v1", list.Objects)
+	}
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"strconv"
+	"strings"
+)
+
+// YAML is written from the JSON of a value, so the field names, their order
+// and what is left out all match the JSON formats without a YAML library.
+
+// yamlField is a field of a JSON object, kept in the order it was written.
+type yamlField struct {
+	key   string
+	value interface{}
+}
+
+type yamlMapping []yamlField
+
+// toYAML converts JSON to a YAML document, without the final newline.
+func toYAML(b []byte) (string, error) {
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+	value, err := decodeOrdered(dec)
+	if err != nil {
+		return "", err
+	}
+	buf := &strings.Builder{}
+	switch v := value.(type) {
+	case yamlMapping:
+		writeYAMLMapping(buf, v, 0, "")
+	case []interface{}:
+		writeYAMLSequence(buf, v, 0)
+	default:
+		buf.WriteString(yamlScalar(v) + "\n")
+	}
+	return strings.TrimSuffix(buf.String(), "\n"), nil
+}
+
+// marshalYAML is json.Marshal followed by toYAML.
+func marshalYAML(v interface{}) string {
+	b, _ := json.Marshal(v)
+	s, _ := toYAML(b)
+	return s
+}
+
+func decodeOrdered(dec *json.Decoder) (interface{}, error) {
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+	switch tok {
+	case json.Delim('{'):
+		mapping := yamlMapping{}
+		for dec.More() {
+			key, err := dec.Token()
+			if err != nil {
+				return nil, err
+			}
+			value, err := decodeOrdered(dec)
+			if err != nil {
+				return nil, err
+			}
+			mapping = append(mapping, yamlField{key: key.(string), value: value})
+		}
+		_, err := dec.Token()
+		return mapping, err
+	case json.Delim('['):
+		seq := []interface{}{}
+		for dec.More() {
+			value, err := decodeOrdered(dec)
+			if err != nil {
+				return nil, err
+			}
+			seq = append(seq, value)
+		}
+		_, err := dec.Token()
+		return seq, err
+	}
+	return tok, nil
+}
+
+// writeYAMLMapping writes each field on its own line at indent. The first line
+// starts with first instead, which is how a mapping is put in a sequence.
+func writeYAMLMapping(buf *strings.Builder, mapping yamlMapping, indent int, first string) {
+	if len(mapping) == 0 {
+		buf.WriteString(first + "{}\n")
+		return
+	}
+	pad := strings.Repeat(" ", indent)
+	for i, f := range mapping {
+		if i == 0 && first != "" {
+			buf.WriteString(first)
+		} else {
+			buf.WriteString(pad)
+		}
+		buf.WriteString(yamlKey(f.key) + ":")
+		switch v := f.value.(type) {
+		case yamlMapping:
+			if len(v) == 0 {
+				buf.WriteString(" {}\n")
+				continue
+			}
+			buf.WriteString("\n")
+			writeYAMLMapping(buf, v, indent+2, "")
+		case []interface{}:
+			if len(v) == 0 {
+				buf.WriteString(" []\n")
+				continue
+			}
+			buf.WriteString("\n")
+			writeYAMLSequence(buf, v, indent+2)
+		default:
+			buf.WriteString(" " + yamlScalar(v) + "\n")
+		}
+	}
+}
+
+func writeYAMLSequence(buf *strings.Builder, seq []interface{}, indent int) {
+	pad := strings.Repeat(" ", indent)
+	for _, item := range seq {
+		switch v := item.(type) {
+		case yamlMapping:
+			writeYAMLMapping(buf, v, indent+2, pad+"- ")
+		case []interface{}:
+			if len(v) == 0 {
+				buf.WriteString(pad + "- []\n")
+				continue
+			}
+			buf.WriteString(pad + "-\n")
+			writeYAMLSequence(buf, v, indent+2)
+		default:
+			buf.WriteString(pad + "- " + yamlScalar(v) + "\n")
+		}
+	}
+}
+
+// yamlScalar always double quotes strings, so values like "true" or "0123"
+// stay strings. Go's escapes are also valid in YAML.
+func yamlScalar(v interface{}) string {
+	switch v := v.(type) {
+	case string:
+		return strconv.Quote(v)
+	case json.Number:
+		return v.String()
+	case bool:
+		return strconv.FormatBool(v)
+	}
+	return "null"
+}
+
+// yamlKey leaves plain field names like Key unquoted and quotes anything
+// else, like the prefixes in PrefixCounts.
+func yamlKey(key string) string {
+	switch strings.ToLower(key) {
+	case "", "y", "n", "yes", "no", "true", "false", "on", "off", "null":
+		return strconv.Quote(key)
+	}
+	for _, r := range key {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '_') {
+			return strconv.Quote(key)
+		}
+	}
+	if key[0] >= '0' && key[0] <= '9' {
+		return strconv.Quote(key)
+	}
+	return key
+}